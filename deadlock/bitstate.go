@@ -0,0 +1,207 @@
+package deadlock
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+)
+
+// bitstateHashes is the number of bits set for each state.
+const bitstateHashes = 3
+
+// Coverage estimates how much of the state space a search covers,
+// apart from the bounds of Options. Only probabilistic searches,
+// e.g. bitstate hashing, may miss states by hash collisions.
+type Coverage struct {
+	// Probabilistic tells whether states may be mistaken for visited ones.
+	Probabilistic bool
+	// Ratio is the estimated ratio of visited states to the ones
+	// which the search would visit without hash collisions.
+	Ratio float64
+	// Collision is the probability that a new state
+	// is mistaken for a visited one at the end of the search.
+	Collision float64
+}
+
+// exact is the coverage of searches which store states exactly.
+var exact = Coverage{Probabilistic: false, Ratio: 1, Collision: 0}
+
+// trail is the path to a queued state in lossy searches,
+// which replaces the upstreams of the states not stored.
+// Each step holds only the label of the fired transition and the id
+// of the reached state, from which the path is replayed.
+type trail struct {
+	label  rule.Label
+	target StateId
+	prev   *trail
+}
+
+// lossyStore is a store which does not keep every visited state,
+// i.e. bitstate hashing or hash compaction.
+type lossyStore interface {
+	StateStore
+	// keep stores the initial state and the states and transitions
+	// of the steps from it.
+	keep(State, []step)
+	coverage() Coverage
+}
+
+// keeper keeps only the states and transitions on the trails
+// to erroneous states, so that their traces are replayable.
+type keeper struct {
+	kept *memoryStore
+}
+
+func newKeeper() keeper {
+	return keeper{kept: NewMemoryStore().(*memoryStore)}
+}
+
+func (k keeper) GetState(id StateId) (State, bool, error) {
+	return k.kept.GetState(id)
+}
+
+func (k keeper) EachState(f func(State) error) error {
+	return k.kept.EachState(f)
+}
+
+// PutTransition discards the transition,
+// since only the ones on the kept trails are needed.
+func (k keeper) PutTransition(t Transition) error {
+	return nil
+}
+
+func (k keeper) GetTransition(id TransitionId) (Transition, bool, error) {
+	return k.kept.GetTransition(id)
+}
+
+func (k keeper) EachTransition(f func(Transition) error) error {
+	return k.kept.EachTransition(f)
+}
+
+func (k keeper) keep(initial State, steps []step) {
+	k.kept.PutState(initial)
+	for _, st := range steps {
+		k.kept.PutState(st.state)
+		k.kept.PutTransition(st.transition)
+	}
+}
+
+// retrace replays the trail from the initial state, firing at each step
+// the transition with the label which reaches the recorded state.
+func retrace(ex expander, tr *trail) (State, []step, error) {
+	path := []*trail{}
+	for ; tr != nil; tr = tr.prev {
+		path = append(path, tr)
+	}
+	initial := ex.initial()
+	from := initial
+	steps := make([]step, 0, len(path))
+	for i := len(path) - 1; i >= 0; i-- {
+		succs, err := ex.successors(from)
+		if err != nil {
+			return nil, nil, err
+		}
+		found := false
+		for _, st := range succs {
+			if st.transition.Label() == path[i].label && st.state.Id() == path[i].target {
+				steps = append(steps, st)
+				from, found = st.state, true
+				break
+			}
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("unreplayable trail to state %s", path[i].target)
+		}
+	}
+	return initial, steps, nil
+}
+
+// bitstateStore sets bits of a fixed-size table for each visited state,
+// like a Bloom filter.
+type bitstateStore struct {
+	keeper
+	mu     sync.Mutex
+	table  []uint64
+	size   uint64
+	count  int
+	filled uint64
+	// missed is the expected number of new states mistaken for visited ones.
+	missed float64
+}
+
+func newBitstateStore(bits int) *bitstateStore {
+	return &bitstateStore{
+		keeper: newKeeper(),
+		table:  make([]uint64, (bits+63)/64),
+		size:   uint64(bits),
+	}
+}
+
+// positions derives the bits of the state by double hashing.
+func (bs *bitstateStore) positions(id StateId) [bitstateHashes]uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	ps := [bitstateHashes]uint64{}
+	for i := range ps {
+		ps[i] = (h1 + uint64(i)*h2) % bs.size
+	}
+	return ps
+}
+
+func (bs *bitstateStore) test(ps [bitstateHashes]uint64) bool {
+	for _, p := range ps {
+		if bs.table[p/64]&(1<<(p%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (bs *bitstateStore) collision() float64 {
+	return math.Pow(float64(bs.filled)/float64(bs.size), bitstateHashes)
+}
+
+func (bs *bitstateStore) PutState(s State) (bool, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	ps := bs.positions(s.Id())
+	if bs.test(ps) {
+		return false, nil
+	}
+	bs.missed += bs.collision()
+	for _, p := range ps {
+		if bs.table[p/64]&(1<<(p%64)) == 0 {
+			bs.table[p/64] |= 1 << (p % 64)
+			bs.filled++
+		}
+	}
+	bs.count++
+	return true, nil
+}
+
+func (bs *bitstateStore) HasState(id StateId) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.test(bs.positions(id))
+}
+
+func (bs *bitstateStore) CountStates() int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.count
+}
+
+func (bs *bitstateStore) coverage() Coverage {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	ratio := 1.0
+	if bs.count > 0 {
+		ratio = float64(bs.count) / (float64(bs.count) + bs.missed)
+	}
+	return Coverage{Probabilistic: true, Ratio: ratio, Collision: bs.collision()}
+}
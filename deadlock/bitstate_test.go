@@ -0,0 +1,185 @@
+package deadlock_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+func TestDetectBitstate(t *testing.T) {
+
+	in := philosophers(3, philosopher)
+
+	full, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		bits      int
+		wantExact bool
+	}{
+		{"large table", 1 << 20, true},
+		{"tiny table", 16, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := deadlock.Options{Bitstate: tt.bits}
+			got, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			cov := got.Coverage()
+			if !cov.Probabilistic {
+				t.Fatalf("want probabilistic coverage, but %+v", cov)
+			}
+			if tt.wantExact != eqStateIds(got.Deadlocked(), full.Deadlocked()) {
+				t.Fatalf("want deadlocks %v, but %v", full.Deadlocked(), got.Deadlocked())
+			}
+			if tt.wantExact != (cov.Ratio > 0.99 && cov.Collision < 0.01) {
+				t.Fatalf("want coverage estimated as exact %v, but %+v", tt.wantExact, cov)
+			}
+			for id := range got.Deadlocked() {
+				if !replayable(got, id) {
+					t.Fatalf("want replayable trace to %s, but not", id)
+				}
+			}
+		})
+	}
+
+	if cov := full.Coverage(); cov.Probabilistic || cov.Ratio != 1 {
+		t.Fatalf("want exact coverage, but %+v", cov)
+	}
+
+}
+
+func replayable(rp deadlock.Report, id deadlock.StateId) bool {
+	visited, traces := rp.Visited(), rp.Traces()
+	for id != rp.Initial() {
+		s, ok := visited[id]
+		if !ok {
+			return false
+		}
+		t, ok := traces[s.Upstream()]
+		if !ok || t.Target() != id {
+			return false
+		}
+		id = t.Source()
+	}
+	return true
+}
+
+func TestDetectBitstateError(t *testing.T) {
+
+	in := deadlock.NewSystem().
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").MoveTo("1")))
+
+	tests := []struct {
+		name     string
+		detector deadlock.Detector
+		opts     deadlock.Options
+	}{
+		{
+			"with store", deadlock.NewDetector(),
+			deadlock.Options{Bitstate: 64, Store: deadlock.NewMemoryStore()},
+		},
+		{
+			"with livelock", deadlock.NewDetector(),
+			deadlock.Options{Bitstate: 64, Livelock: true},
+		},
+		{
+			"parallel", deadlock.NewParallelDetector(2),
+			deadlock.Options{Bitstate: 64},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.detector.DetectContext(context.Background(), in, tt.opts)
+			if err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+		})
+	}
+
+}
+
+func TestDetectLossyMemory(t *testing.T) {
+
+	// a single long path through large states, where the trail
+	// of the deepest state spans half of the state space at the sample
+	const length, width = 4000, 64
+	init := vars.Shared{"x": 0}
+	for i := 0; i < width; i++ {
+		init[vars.Name(fmt.Sprintf("pad%d", i))] = i
+	}
+	in := deadlock.NewSystem().
+		Declare(init).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("x").IsLessThan(length)).
+				Let("incr", do.Add(1).ToVar("x")).MoveTo("0")))
+
+	full := retainedBytes(t, in, deadlock.Options{}, length/2)
+
+	tests := []struct {
+		name string
+		opts deadlock.Options
+	}{
+		{"bitstate", deadlock.Options{Bitstate: 1 << 20}},
+		{"hash compaction", deadlock.Options{HashCompaction: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retainedBytes(t, in, tt.opts, length/2); 4*got >= full {
+				t.Fatalf("want retained bytes less than a quarter of %d, but %d", full, got)
+			}
+		})
+	}
+
+}
+
+// retainedBytes measures the live heap which the search retains
+// when it has visited the given number of states, and checks that
+// the deadlock at the end of the search is replayable.
+func retainedBytes(t *testing.T, in deadlock.System, opts deadlock.Options, states int) int64 {
+
+	var base, sampled runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&base)
+
+	opts.ObserveInterval = time.Nanosecond
+	opts.Observer = func(st deadlock.Statistics) {
+		if st.States == states {
+			runtime.GC()
+			runtime.ReadMemStats(&sampled)
+		}
+	}
+	rp, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if len(rp.Deadlocked()) != 1 {
+		t.Fatalf("want 1 deadlock, but %v", rp.Deadlocked())
+	}
+	for id := range rp.Deadlocked() {
+		if !replayable(rp, id) {
+			t.Fatalf("want replayable trace to %s, but not", id)
+		}
+	}
+	return int64(sampled.HeapAlloc) - int64(base.HeapAlloc)
+
+}
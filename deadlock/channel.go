@@ -0,0 +1,153 @@
+package deadlock
+
+import (
+	"fmt"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+// channelVars returns the variables which store the messages of a buffered channel,
+// i.e. the number of the messages and the slots of the queue.
+// Unbuffered channels store nothing.
+func channelVars(ch vars.Name, capacity int) []vars.Name {
+	xs := []vars.Name{}
+	if capacity <= 0 {
+		return xs
+	}
+	xs = append(xs, lengthVar(ch))
+	for i := 0; i < capacity; i++ {
+		xs = append(xs, slotVar(ch, i))
+	}
+	return xs
+}
+
+func lengthVar(ch vars.Name) vars.Name {
+	return ch + ".len"
+}
+
+func slotVar(ch vars.Name, i int) vars.Name {
+	return vars.Name(fmt.Sprintf("%s[%d]", ch, i))
+}
+
+func validateChannels(s System) error {
+	declared := s.Declared()
+	for ch, capacity := range s.Channels() {
+		if capacity < 0 {
+			return fmt.Errorf("negative capacity: %s", ch)
+		}
+		for _, x := range channelVars(ch, capacity) {
+			if _, ok := declared[x]; ok {
+				return fmt.Errorf("variable %s collides with channel %s", x, ch)
+			}
+		}
+	}
+	for _, p := range s.Processes() {
+		for _, rs := range p.Rules() {
+			for _, r := range rs {
+				c, ok := r.Communication()
+				if !ok {
+					continue
+				}
+				if _, ok := s.Channels()[c.Channel]; !ok {
+					return fmt.Errorf("undeclared channel: %s", c.Channel)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// message returns the value sent by the communication.
+func message(c rule.Communication, vs vars.Shared) (int, error) {
+	if c.Var == "" {
+		return c.Value, nil
+	}
+	n, ok := vs[c.Var]
+	if !ok {
+		return 0, fmt.Errorf("undeclared variable: %s", c.Var)
+	}
+	return n, nil
+}
+
+// deliver stores the received message into the variable of the communication.
+func deliver(c rule.Communication, n int, vs vars.Shared) (vars.Shared, error) {
+	if c.Var == "" {
+		return vs, nil
+	}
+	if _, ok := vs[c.Var]; !ok {
+		return nil, fmt.Errorf("undeclared variable: %s", c.Var)
+	}
+	next := vs.Clone()
+	next[c.Var] = n
+	return next, nil
+}
+
+// communicate performs the communication on a buffered channel.
+// It reports false if the channel is full for sending or empty for receiving.
+func communicate(c rule.Communication, capacity int, vs vars.Shared) (vars.Shared, bool, error) {
+
+	length := vs[lengthVar(c.Channel)]
+
+	if c.Direction == rule.Sending {
+		if length >= capacity {
+			return nil, false, nil
+		}
+		n, err := message(c, vs)
+		if err != nil {
+			return nil, false, err
+		}
+		next := vs.Clone()
+		next[slotVar(c.Channel, length)] = n
+		next[lengthVar(c.Channel)] = length + 1
+		return next, true, nil
+	}
+
+	if length == 0 {
+		return nil, false, nil
+	}
+	next := vs.Clone()
+	n := next[slotVar(c.Channel, 0)]
+	for i := 1; i < length; i++ {
+		next[slotVar(c.Channel, i-1)] = next[slotVar(c.Channel, i)]
+	}
+	// vacant slots are cleared not to distinguish the same contents
+	next[slotVar(c.Channel, length-1)] = 0
+	next[lengthVar(c.Channel)] = length - 1
+	next, err := deliver(c, n, next)
+	if err != nil {
+		return nil, false, err
+	}
+	return next, true, nil
+
+}
+
+// rendezvous fires the sending rule on an unbuffered channel
+// together with every matching receiving rule of the other processes.
+func (ex expander) rendezvous(p Process, from State, ref ruleRef) ([]step, error) {
+
+	steps := []step{}
+	c, _ := p.Rules()[ref.source][ref.index].Communication()
+	for _, q := range ex.system.Processes() {
+		if q.Id() == p.Id() {
+			continue
+		}
+		focus := from.Locations()[q.Id()]
+		for i, o := range q.Rules()[focus] {
+			d, ok := o.Communication()
+			if !ok || d.Direction != rule.Receiving || d.Channel != c.Channel {
+				continue
+			}
+			partner := participant{process: q.Id(), label: o.Label(), rule: ruleRef{source: focus, index: i}}
+			st, ok, err := ex.fireJoint(p, from, ref, []participant{partner})
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				steps = append(steps, st)
+			}
+		}
+	}
+	return steps, nil
+
+}
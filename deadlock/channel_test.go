@@ -0,0 +1,289 @@
+package deadlock_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+func TestChannel(t *testing.T) {
+
+	// each process sends a message first and then receives the other's
+	crossing := func(capacity int) deadlock.System {
+		exchange := func(out, in vars.Name, n int, x vars.Name) deadlock.Process {
+			return deadlock.NewProcess().
+				EnterAt("0").
+				Define(rule.At("0").Send(out, n).MoveTo("1")).
+				Define(rule.At("1").Receive(in, x).MoveTo("2")).
+				HaltAt("2")
+		}
+		return deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0, "y": 0}).
+			Channel("a", capacity).
+			Channel("b", capacity).
+			Register("P", exchange("a", "b", 1, "x")).
+			Register("Q", exchange("b", "a", 2, "y"))
+	}
+
+	tests := []struct {
+		name         string
+		in           deadlock.System
+		wantDeadlock bool
+		wantFinal    vars.Shared
+	}{
+		{"unbuffered", crossing(0), true, nil},
+		{"buffered", crossing(1), false, vars.Shared{"x": 2, "y": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deadlock.NewDetector().Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if tt.wantDeadlock != (len(got.Deadlocked()) > 0) {
+				t.Fatalf("want deadlocks %v, but %v", tt.wantDeadlock, got.Deadlocked())
+			}
+			reduced, err := deadlock.NewDetector().DetectContext(context.Background(), tt.in, deadlock.Options{PartialOrder: true})
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !eqStateIds(reduced.Deadlocked(), got.Deadlocked()) {
+				t.Fatalf("want deadlocks %v, but %v", got.Deadlocked(), reduced.Deadlocked())
+			}
+			parallel, err := deadlock.NewParallelDetector(2).Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !eqReports(parallel, got) {
+				t.Fatalf("want %+v, but %+v", summarize(got), summarize(parallel))
+			}
+			for _, st := range got.Accepting() {
+				for x, n := range tt.wantFinal {
+					if st.SharedVars()[x] != n {
+						t.Fatalf("want %s = %d, but %v", x, n, st.SharedVars())
+					}
+				}
+			}
+		})
+	}
+
+}
+
+func TestChannelRendezvous(t *testing.T) {
+
+	// the consumer receives messages until the producer stops
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		Channel("ch", 0).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Send("ch", 1).MoveTo("1")).
+			Define(rule.At("1").Send("ch", 2).MoveTo("2")).
+			HaltAt("2")).
+		Register("C", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Receive("ch", "x").MoveTo("0")))
+
+	got, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if len(got.Visited()) != 3 || len(got.Transited()) != 2 || len(got.Deadlocked()) != 1 {
+		t.Fatalf("want 3 states in a row, but %+v", summarize(got))
+	}
+	for id, st := range got.Deadlocked() {
+		if st.SharedVars()["x"] != 2 {
+			t.Fatalf("want the last message received, but %v", st.SharedVars())
+		}
+		exp, err := deadlock.Explain(in, st)
+		if err != nil {
+			t.Fatalf("want no error, but has error %v", err)
+		}
+		c := exp.Processes[1].Rules[0]
+		if c.Fireable || c.Communication != "ch?x" || c.Channel != "no sender on ch" {
+			t.Fatalf("want blocked for no sender, but %+v", c)
+		}
+		if path := got.TraceTo(id); len(path) != 2 || path[0].Label() != "ch!1" ||
+			!eqProcessIds(path[0].Processes(), []deadlock.ProcessId{"P", "C"}) {
+			t.Fatalf("want joint transitions by the sender, but %v", path)
+		}
+	}
+
+	var out bytes.Buffer
+	if _, err := deadlock.NewPrinter(&out).Print(got); err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if !strings.Contains(out.String(), "P.ch!1 / C.ch?x") {
+		t.Fatalf("want joint labels, but %s", out.String())
+	}
+
+}
+
+func TestChannelBuffer(t *testing.T) {
+
+	// the consumer receives one more message than the producer sends
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0, "y": 0, "z": 0, "w": 0}).
+		Channel("ch", 2).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Send("ch", 1).MoveTo("1")).
+			Define(rule.At("1").Send("ch", 2).MoveTo("2")).
+			Define(rule.At("2").Send("ch", 3).MoveTo("3")).
+			HaltAt("3")).
+		Register("C", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Receive("ch", "x").MoveTo("1")).
+			Define(rule.At("1").Receive("ch", "y").MoveTo("2")).
+			Define(rule.At("2").Receive("ch", "z").MoveTo("3")).
+			Define(rule.At("3").Receive("ch", "w").MoveTo("4")))
+
+	got, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if len(got.Deadlocked()) != 1 {
+		t.Fatalf("want a deadlock, but %v", got.Deadlocked())
+	}
+	for _, st := range got.Deadlocked() {
+		vs := st.SharedVars()
+		if vs["x"] != 1 || vs["y"] != 2 || vs["z"] != 3 || vs["ch.len"] != 0 {
+			t.Fatalf("want every message received in order, but %v", st.SharedVars())
+		}
+		exp, err := deadlock.Explain(in, st)
+		if err != nil {
+			t.Fatalf("want no error, but has error %v", err)
+		}
+		if c := exp.Processes[1].Rules[0]; c.Fireable || c.Channel != "ch is empty" {
+			t.Fatalf("want blocked for the empty channel, but %+v", c)
+		}
+	}
+
+	for _, rp := range []deadlock.Report{got, wrappedReport{Report: got}} {
+		var out bytes.Buffer
+		if _, err := deadlock.NewPrinter(&out).Print(rp); err != nil {
+			t.Fatalf("want no error, but has error %v", err)
+		}
+		if !strings.Contains(out.String(), "ch = [2, 3]") || strings.Contains(out.String(), "ch[0]") {
+			t.Fatalf("want channels printed as queues, but %s", out.String())
+		}
+	}
+
+}
+
+// wrappedReport and wrappedSystem are implemented outside of the package.
+type wrappedReport struct {
+	deadlock.Report
+}
+
+type wrappedSystem struct {
+	deadlock.System
+}
+
+func TestChannelLookalike(t *testing.T) {
+
+	// the variable is not a channel, though named like the ones storing it
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x.len": 3}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").MoveTo("1")))
+
+	got, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	var out bytes.Buffer
+	if _, err := deadlock.NewPrinter(&out).Print(got); err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if !strings.Contains(out.String(), "x.len = 3") || strings.Contains(out.String(), "x = []") {
+		t.Fatalf("want the variable printed as it is, but %s", out.String())
+	}
+
+}
+
+func TestChannelPartialOrder(t *testing.T) {
+
+	// the declared footprint does not hide the channel from the reduction
+	in := deadlock.NewSystem().
+		Channel("c", 1).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Touch(vars.Access(nil, nil)).Send("c", 1).MoveTo("1"))).
+		Register("Q", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Send("c", 2).MoveTo("1")).
+			Define(rule.At("0").Receive("c", "").MoveTo("2")))
+
+	got, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if len(got.Deadlocked()) == 0 {
+		t.Fatalf("want deadlocks, but %+v", summarize(got))
+	}
+	reduced, err := deadlock.NewDetector().DetectContext(context.Background(), in, deadlock.Options{PartialOrder: true})
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if !eqStateIds(reduced.Deadlocked(), got.Deadlocked()) {
+		t.Fatalf("want deadlocks %v, but %v", got.Deadlocked(), reduced.Deadlocked())
+	}
+
+}
+
+func TestChannelError(t *testing.T) {
+
+	sender := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").SendVar("ch", "x").MoveTo("1"))
+	receiver := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").Receive("ch", "y").MoveTo("1"))
+
+	tests := []struct {
+		name string
+		in   deadlock.System
+	}{
+		{"undeclared channel", deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0}).
+			Register("P", sender)},
+		{"negative capacity", deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0}).
+			Channel("ch", -1).
+			Register("P", sender)},
+		{"undeclared sent variable", deadlock.NewSystem().
+			Declare(vars.Shared{"y": 0}).
+			Channel("ch", 1).
+			Register("P", sender)},
+		{"undeclared received variable", deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0}).
+			Channel("ch", 0).
+			Register("P", sender).
+			Register("Q", receiver)},
+		{"colliding variable", deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0, "ch.len": 0}).
+			Channel("ch", 1).
+			Register("P", sender)},
+		{"colliding variable in wrapped system", wrappedSystem{System: deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0, "ch.len": 0}).
+			Channel("ch", 1).
+			Register("P", sender)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := deadlock.NewDetector().Detect(tt.in); err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+		})
+	}
+
+}
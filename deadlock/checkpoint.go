@@ -0,0 +1,346 @@
+package deadlock
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+const checkpointVersion = 1
+
+// checkpoint is the progress of a search saved in a file.
+// States and transitions are encoded as in the disk store.
+type checkpoint struct {
+	Version     int
+	System      string
+	Options     savedOptions
+	Initial     string
+	States      [][]byte
+	Transitions [][]byte
+	Queue       []savedQueued
+	Accepting   []string
+	Deadlocked  []string
+	Violated    map[string][]string
+	Shallowest  map[string]int
+	Progress    savedProgress
+}
+
+// savedOptions is the part of Options which can be saved,
+// except the ones given again on resuming.
+type savedOptions struct {
+	MaxStates           int
+	MaxDepth            int
+	Livelock            bool
+	PartialOrder        bool
+	Collapse            bool
+	Strategy            Strategy
+	StopAtFirstDeadlock bool
+	Checkpoint          string
+	CheckpointInterval  time.Duration
+}
+
+// savedProgress is the counters of the progress.
+type savedProgress struct {
+	Transitions int
+	MaxDepth    int
+}
+
+type savedQueued struct {
+	State []byte
+	Depth int
+}
+
+// save writes the checkpoint into a temporary file and renames it,
+// so that a crash during the save does not break the previous one.
+func (sr *search) save(s System, opts Options, ex expander) error {
+
+	cp := checkpoint{
+		Version: checkpointVersion,
+		System:  digest(s),
+		Options: savedOptions{
+			MaxStates:           opts.MaxStates,
+			MaxDepth:            opts.MaxDepth,
+			Livelock:            opts.Livelock,
+			PartialOrder:        opts.PartialOrder,
+			Collapse:            opts.Collapse,
+			Strategy:            opts.Strategy,
+			StopAtFirstDeadlock: opts.StopAtFirstDeadlock,
+			Checkpoint:          opts.Checkpoint,
+			CheckpointInterval:  opts.CheckpointInterval,
+		},
+		Initial:    string(sr.initial),
+		Violated:   map[string][]string{},
+		Shallowest: map[string]int{},
+		Progress: savedProgress{
+			Transitions: sr.progress.transitions,
+			MaxDepth:    sr.progress.maxDepth,
+		},
+	}
+
+	err := sr.store.EachState(func(st State) error {
+		cp.States = append(cp.States, encodeState(st))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = sr.store.EachTransition(func(t Transition) error {
+		cp.Transitions = append(cp.Transitions, encodeTransition(t))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, q := range queuedItems(sr.queue) {
+		cp.Queue = append(cp.Queue, savedQueued{State: encodeState(q.state), Depth: q.depth})
+	}
+	for id := range sr.accepting {
+		cp.Accepting = append(cp.Accepting, string(id))
+	}
+	for id := range sr.deadlocked {
+		cp.Deadlocked = append(cp.Deadlocked, string(id))
+	}
+	for id, names := range sr.violated {
+		cp.Violated[string(id)] = names
+	}
+	for id, d := range sr.shallowest {
+		cp.Shallowest[string(id)] = d
+	}
+
+	tmp := opts.Checkpoint + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(cp); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, opts.Checkpoint)
+
+}
+
+// queuedItems returns the items of the frontier in its internal order.
+func queuedItems(queue frontier) []queued {
+	switch q := queue.(type) {
+	case *fifo:
+		return q.items
+	case *lifo:
+		return q.items
+	}
+	return nil
+}
+
+// Resume continues the search saved in the checkpoint file,
+// with the same options as the search which saved it but no timeout.
+// The system should be defined in the same way as the saved one.
+func Resume(path string, s System) (Report, error) {
+	return ResumeContext(context.Background(), path, s, Options{})
+}
+
+// ResumeContext continues the search saved in the checkpoint file
+// within the given context. See Resume. Timeout, Observer and
+// ObserveInterval of the overrides apply to the resumed search,
+// and the other options are the saved ones.
+func ResumeContext(ctx context.Context, path string, s System, overrides Options) (Report, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return report{}, err
+	}
+	defer f.Close()
+	cp := checkpoint{}
+	if err := gob.NewDecoder(f).Decode(&cp); err != nil {
+		return report{}, err
+	}
+	if cp.Version != checkpointVersion {
+		return report{}, fmt.Errorf("unsupported checkpoint version: %d", cp.Version)
+	}
+	if cp.System != digest(s) {
+		return report{}, fmt.Errorf("checkpoint does not match the system")
+	}
+
+	opts := Options{
+		MaxStates:           cp.Options.MaxStates,
+		MaxDepth:            cp.Options.MaxDepth,
+		Timeout:             overrides.Timeout,
+		Livelock:            cp.Options.Livelock,
+		PartialOrder:        cp.Options.PartialOrder,
+		Collapse:            cp.Options.Collapse,
+		Strategy:            cp.Options.Strategy,
+		StopAtFirstDeadlock: cp.Options.StopAtFirstDeadlock,
+		Checkpoint:          cp.Options.Checkpoint,
+		CheckpointInterval:  cp.Options.CheckpointInterval,
+		Observer:            overrides.Observer,
+		ObserveInterval:     overrides.ObserveInterval,
+	}
+	ctx, cancel := opts.context(ctx)
+	defer cancel()
+
+	ex, err := newExpander(s, opts)
+	if err != nil {
+		return report{}, err
+	}
+
+	sr, err := restore(cp, s, opts, ex)
+	if err != nil {
+		return report{}, err
+	}
+	if err := sr.run(ctx, s, opts, ex); err != nil {
+		return report{}, err
+	}
+	return sr.finish(s, opts, ex)
+
+}
+
+func restore(cp checkpoint, s System, opts Options, ex expander) (*search, error) {
+
+	sr := &search{
+		store:      NewMemoryStore(),
+		queue:      newFrontier(s, opts),
+		initial:    StateId(cp.Initial),
+		accepting:  StateSet{},
+		deadlocked: StateSet{},
+		violated:   map[StateId][]string{},
+		reason:     Exhausted,
+		progress:   newProgress(),
+	}
+	sr.progress.transitions = cp.Progress.Transitions
+	sr.progress.maxDepth = cp.Progress.MaxDepth
+
+	decode := func(body []byte) (State, error) {
+		st, err := decodeState(body)
+		if err != nil || ex.collapser == nil {
+			return st, err
+		}
+		return ex.collapser.collapse(ex.encoder, st.(state)), nil
+	}
+	for _, body := range cp.States {
+		st, err := decode(body)
+		if err != nil {
+			return nil, err
+		}
+		sr.store.PutState(st)
+	}
+	for _, body := range cp.Transitions {
+		t, err := decodeTransition(body)
+		if err != nil {
+			return nil, err
+		}
+		sr.store.PutTransition(t)
+	}
+
+	items := []queued{}
+	for _, q := range cp.Queue {
+		st, err := decode(q.State)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, queued{state: st, depth: q.Depth})
+	}
+	switch q := sr.queue.(type) {
+	case *fifo:
+		q.items = items
+	case *lifo:
+		q.items = items
+	}
+
+	for _, id := range cp.Accepting {
+		st, _, _ := sr.store.GetState(StateId(id))
+		sr.accepting[StateId(id)] = st
+	}
+	for _, id := range cp.Deadlocked {
+		st, _, _ := sr.store.GetState(StateId(id))
+		sr.deadlocked[StateId(id)] = st
+	}
+	for id, names := range cp.Violated {
+		sr.violated[StateId(id)] = names
+	}
+	if opts.Strategy != BreadthFirst && opts.MaxDepth > 0 {
+		sr.shallowest = map[StateId]int{}
+		for id, d := range cp.Shallowest {
+			sr.shallowest[StateId(id)] = d
+		}
+	}
+	return sr, nil
+
+}
+
+// digest summarizes the definition of the system to validate checkpoints.
+// User-defined guards and actions cannot be distinguished from each other.
+func digest(s System) string {
+
+	b := strings.Builder{}
+	for _, p := range s.Processes() {
+		fmt.Fprintf(&b, "process %s enter %s halt %v fair %d\n",
+			p.Id(), p.EntryPoint(), p.HaltingPoints(), p.Fairness())
+		for _, y := range localNames(p) {
+			fmt.Fprintf(&b, "local %s = %d\n", y, p.LocalVars()[y])
+		}
+		locs := []rule.Location{}
+		for l := range p.Rules() {
+			locs = append(locs, l)
+		}
+		sort.Slice(locs, func(i, j int) bool { return locs[i] < locs[j] })
+		for _, l := range locs {
+			for _, r := range p.Rules()[l] {
+				fmt.Fprintf(&b, "rule %s %s -> %s if %s do %s progress %t fair %d",
+					l, r.Label(), r.Target(), when.Describe(r.Guard()), do.Describe(r.Action()),
+					r.IsProgress(), r.Fairness())
+				if c, ok := r.Communication(); ok {
+					fmt.Fprintf(&b, " communicate %s", c)
+				}
+				if e, ok := r.Event(); ok {
+					fmt.Fprintf(&b, " sync %s", e)
+				}
+				if f, ok := r.Footprint(); ok {
+					fmt.Fprintf(&b, " reads %v writes %v", f.Reads(), f.Writes())
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	xs := []vars.Name{}
+	for x := range s.InitVars() {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	for _, x := range xs {
+		fmt.Fprintf(&b, "var %s = %d\n", x, s.InitVars()[x])
+	}
+	chs := []vars.Name{}
+	for ch := range s.Channels() {
+		chs = append(chs, ch)
+	}
+	sort.Slice(chs, func(i, j int) bool { return chs[i] < chs[j] })
+	for _, ch := range chs {
+		fmt.Fprintf(&b, "channel %s capacity %d\n", ch, s.Channels()[ch])
+	}
+	for _, inv := range s.Invariants() {
+		fmt.Fprintf(&b, "invariant %s\n", inv.Name())
+	}
+	for _, group := range s.Symmetries() {
+		b.WriteString("symmetric")
+		for _, r := range group {
+			fmt.Fprintf(&b, " %s%v", r.Id(), r.Owned())
+		}
+		b.WriteString("\n")
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum([]byte(b.String())))
+
+}
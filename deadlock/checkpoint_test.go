@@ -0,0 +1,229 @@
+package deadlock_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+func TestResume(t *testing.T) {
+
+	// cancel the search after the guard is tested a number of times
+	tested := 0
+	var cancel context.CancelFunc = func() {}
+	guard := when.GuardFunc(func(vs vars.Shared) (bool, error) {
+		tested++
+		if tested == 100 {
+			cancel()
+		}
+		return vs["x"] < 20, nil
+	})
+	counter := func(x vars.Name, g when.Guard) deadlock.Process {
+		return deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(g).Let("incr", do.Add(1).ToVar(x)).MoveTo("0"))
+	}
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0, "y": 0}).
+		Register("P", counter("x", guard)).
+		Register("Q", counter("y", when.Var("y").IsLessThan(20)))
+
+	want, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts deadlock.Options
+	}{
+		{"breadth-first", deadlock.Options{}},
+		{"depth-first", deadlock.Options{Strategy: deadlock.DepthFirst}},
+		{"bounded depth-first", deadlock.Options{Strategy: deadlock.DepthFirst, MaxDepth: 45}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ddsv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "search.checkpoint")
+
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+			tested = 0
+
+			opts := tt.opts
+			opts.Checkpoint = path
+			partial, err := deadlock.NewDetector().DetectContext(ctx, in, opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if partial.StopReason() != deadlock.Canceled {
+				t.Fatalf("want canceled, but %v", partial.StopReason())
+			}
+
+			got, err := deadlock.Resume(path, in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !got.Complete() || !eqStateIds(got.Visited(), want.Visited()) ||
+				!eqStateIds(got.Deadlocked(), want.Deadlocked()) ||
+				!eqTransitionIds(got.Transited(), want.Transited()) {
+				t.Fatalf("want %+v, but %+v", summarize(want), summarize(got))
+			}
+			if tt.opts.Strategy == deadlock.BreadthFirst && !eqReports(got, want) {
+				t.Fatalf("want %+v, but %+v", summarize(want), summarize(got))
+			}
+		})
+	}
+
+}
+
+func TestResumeAfterTimeout(t *testing.T) {
+
+	// stall the search once, so that it times out there
+	tested := 0
+	guard := when.GuardFunc(func(vs vars.Shared) (bool, error) {
+		tested++
+		if tested == 100 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return vs["x"] < 20, nil
+	})
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0, "y": 0}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(guard).Let("incr", do.Add(1).ToVar("x")).MoveTo("0"))).
+		Register("Q", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("y").IsLessThan(20)).
+				Let("incr", do.Add(1).ToVar("y")).MoveTo("0")))
+
+	want, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "ddsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "search.checkpoint")
+
+	tested = 0
+	opts := deadlock.Options{Checkpoint: path, Timeout: 10 * time.Millisecond}
+	partial, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if partial.StopReason() != deadlock.TimedOut {
+		t.Fatalf("want timed out, but %v", partial.StopReason())
+	}
+
+	// the saved timeout would stop the resumed search at the stall again
+	tested = 0
+	observed := []deadlock.Statistics{}
+	overrides := deadlock.Options{
+		Timeout:         time.Minute,
+		Observer:        func(st deadlock.Statistics) { observed = append(observed, st) },
+		ObserveInterval: time.Nanosecond,
+	}
+	got, err := deadlock.ResumeContext(context.Background(), path, in, overrides)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if !eqReports(got, want) {
+		t.Fatalf("want %+v, but %+v", summarize(want), summarize(got))
+	}
+	if len(observed) == 0 {
+		t.Fatalf("want the observer notified, but not")
+	}
+	if observed[0].Transitions < partial.Statistics().Transitions {
+		t.Fatalf("want the counters kept from %+v, but %+v", partial.Statistics(), observed[0])
+	}
+	if st := got.Statistics(); st.Transitions != want.Statistics().Transitions ||
+		st.MaxDepth != want.Statistics().MaxDepth {
+		t.Fatalf("want statistics %+v, but %+v", want.Statistics(), st)
+	}
+
+}
+
+func TestCheckpointPeriodically(t *testing.T) {
+
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("x").IsLessThan(50)).
+				Let("incr", do.Add(1).ToVar("x")).MoveTo("0")))
+
+	dir, err := ioutil.TempDir("", "ddsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "search.checkpoint")
+
+	opts := deadlock.Options{Checkpoint: path, CheckpointInterval: time.Nanosecond}
+	want, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	got, err := deadlock.Resume(path, in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if !eqReports(got, want) {
+		t.Fatalf("want %+v, but %+v", summarize(want), summarize(got))
+	}
+
+	other := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("x").IsLessThan(60)).
+				Let("incr", do.Add(1).ToVar("x")).MoveTo("0")))
+	if _, err := deadlock.Resume(path, other); err == nil {
+		t.Fatalf("want error for the different system, but has no error")
+	}
+
+	if _, err := deadlock.Resume(filepath.Join(dir, "missing"), in); err == nil {
+		t.Fatalf("want error for the missing file, but has no error")
+	}
+
+	unsupported := []struct {
+		name     string
+		detector deadlock.Detector
+		opts     deadlock.Options
+	}{
+		{"parallel", deadlock.NewParallelDetector(2), deadlock.Options{Checkpoint: path}},
+		{"state store", deadlock.NewDetector(), deadlock.Options{Checkpoint: path, Store: deadlock.NewMemoryStore()}},
+		{"bitstate", deadlock.NewDetector(), deadlock.Options{Checkpoint: path, Bitstate: 64}},
+		{"hash compaction", deadlock.NewDetector(), deadlock.Options{Checkpoint: path, HashCompaction: true}},
+		{"iterative deepening", deadlock.NewDetector(), deadlock.Options{Checkpoint: path, Strategy: deadlock.IterativeDeepening}},
+		{"best-first", deadlock.NewDetector(), deadlock.Options{Checkpoint: path, Strategy: deadlock.BestFirst}},
+	}
+	for _, u := range unsupported {
+		t.Run(u.name, func(t *testing.T) {
+			if _, err := u.detector.DetectContext(context.Background(), in, u.opts); err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+		})
+	}
+
+}
@@ -0,0 +1,206 @@
+package deadlock
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+// Memory summarizes the space which the visited states occupy.
+// The sizes are estimated from the payloads, i.e. ids, names and values,
+// excluding the overhead of Go's runtime such as map buckets.
+type Memory struct {
+	// States is the number of entries in the visited set.
+	States int
+	// VisitedBytes is the estimated size of the visited set in memory,
+	// which is zero if the store is unknown.
+	VisitedBytes int64
+	// Components is the number of distinct location sets and variables
+	// shared by the states, which is zero unless they are collapsed.
+	Components int
+	// ComponentBytes is the estimated size of the shared components.
+	ComponentBytes int64
+}
+
+// compactStore holds only 64-bit fingerprints of the visited states.
+type compactStore struct {
+	keeper
+	mu           sync.Mutex
+	fingerprints map[uint64]struct{}
+	// missed is the expected number of new states mistaken for visited ones.
+	missed float64
+}
+
+func newCompactStore() *compactStore {
+	return &compactStore{
+		keeper:       newKeeper(),
+		fingerprints: map[uint64]struct{}{},
+	}
+}
+
+func fingerprint(id StateId) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	return h.Sum64()
+}
+
+// collision is the probability that a new fingerprint
+// coincides with one of the stored ones.
+func (cs *compactStore) collision() float64 {
+	return float64(len(cs.fingerprints)) / math.Pow(2, 64)
+}
+
+func (cs *compactStore) PutState(s State) (bool, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	fp := fingerprint(s.Id())
+	if _, ok := cs.fingerprints[fp]; ok {
+		return false, nil
+	}
+	cs.missed += cs.collision()
+	cs.fingerprints[fp] = struct{}{}
+	return true, nil
+}
+
+func (cs *compactStore) HasState(id StateId) bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	_, ok := cs.fingerprints[fingerprint(id)]
+	return ok
+}
+
+func (cs *compactStore) CountStates() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return len(cs.fingerprints)
+}
+
+func (cs *compactStore) coverage() Coverage {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	n := float64(len(cs.fingerprints))
+	ratio := 1.0
+	if n > 0 {
+		ratio = n / (n + cs.missed)
+	}
+	return Coverage{Probabilistic: true, Ratio: ratio, Collision: cs.collision()}
+}
+
+// collapser shares the location sets and the variables among states,
+// so that each distinct component is held only once.
+type collapser struct {
+	mu        sync.Mutex
+	locations map[string]LocationSet
+	vars      map[string]vars.Shared
+	locals    map[string]LocalVarSet
+	bytes     int64
+}
+
+func newCollapser() *collapser {
+	return &collapser{
+		locations: map[string]LocationSet{},
+		vars:      map[string]vars.Shared{},
+		locals:    map[string]LocalVarSet{},
+	}
+}
+
+// collapse replaces the components of the state
+// with the equal ones which previous states hold.
+func (c *collapser) collapse(enc encoder, st state) state {
+	lkey := string(enc.appendLocations(nil, st.locations))
+	vkey := string(enc.appendVars(nil, st.sharedVars))
+	xkey := string(enc.appendLocals(nil, st.localVars))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ls, ok := c.locations[lkey]; ok {
+		st.locations = ls
+	} else {
+		c.locations[lkey] = st.locations
+		c.bytes += locationsSize(st.locations)
+	}
+	if vs, ok := c.vars[vkey]; ok {
+		st.sharedVars = vs
+	} else {
+		c.vars[vkey] = st.sharedVars
+		c.bytes += varsSize(st.sharedVars)
+	}
+	if len(st.localVars) == 0 {
+		return st
+	}
+	if xs, ok := c.locals[xkey]; ok {
+		st.localVars = xs
+	} else {
+		c.locals[xkey] = st.localVars
+		c.bytes += localsSize(st.localVars)
+	}
+	return st
+}
+
+func (c *collapser) memory() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.locations) + len(c.vars) + len(c.locals), c.bytes
+}
+
+// pointerSize is the size of a reference to a collapsed component.
+const pointerSize = 8
+
+func locationsSize(ls LocationSet) int64 {
+	n := int64(0)
+	for pid, l := range ls {
+		n += int64(len(pid) + len(l))
+	}
+	return n
+}
+
+func varsSize(vs vars.Shared) int64 {
+	n := int64(0)
+	for x := range vs {
+		n += int64(len(x)) + 8
+	}
+	return n
+}
+
+func localsSize(locals LocalVarSet) int64 {
+	n := int64(0)
+	for pid, vs := range locals {
+		n += int64(len(pid)) + varsSize(vs)
+	}
+	return n
+}
+
+// measure estimates the memory of the visited set in the store,
+// reading the sizes counted by the store and the collapser.
+func measure(store StateStore, col *collapser) Memory {
+
+	mem := Memory{States: store.CountStates()}
+	if col != nil {
+		mem.Components, mem.ComponentBytes = col.memory()
+	}
+
+	switch st := store.(type) {
+	case *memoryStore:
+		st.mu.RLock()
+		mem.VisitedBytes = st.keyBytes
+		if col != nil {
+			// the states refer to the collapsed components
+			mem.VisitedBytes += int64(2*len(st.states)+st.withLocals)*pointerSize + mem.ComponentBytes
+		} else {
+			mem.VisitedBytes += st.payloadBytes
+		}
+		st.mu.RUnlock()
+	case *diskStore:
+		// only the index is held in memory
+		st.mu.Lock()
+		mem.VisitedBytes = st.indexBytes
+		st.mu.Unlock()
+	case *bitstateStore:
+		mem.VisitedBytes = int64(len(st.table)) * 8
+	case *compactStore:
+		mem.VisitedBytes = int64(mem.States) * 8
+	}
+	return mem
+
+}
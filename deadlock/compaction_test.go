@@ -0,0 +1,73 @@
+package deadlock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+)
+
+func TestDetectCompaction(t *testing.T) {
+
+	in := philosophers(3, philosopher)
+
+	full, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if mem := full.Memory(); mem.States != len(full.Visited()) || mem.Components != 0 {
+		t.Fatalf("want memory of %d uncollapsed states, but %+v", len(full.Visited()), mem)
+	}
+
+	t.Run("hash compaction", func(t *testing.T) {
+		opts := deadlock.Options{HashCompaction: true}
+		got, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts)
+		if err != nil {
+			t.Fatalf("want no error, but has error %v", err)
+		}
+		if !eqStateIds(got.Deadlocked(), full.Deadlocked()) {
+			t.Fatalf("want deadlocks %v, but %v", full.Deadlocked(), got.Deadlocked())
+		}
+		for id := range got.Deadlocked() {
+			if !replayable(got, id) {
+				t.Fatalf("want replayable trace to %s, but not", id)
+			}
+		}
+		if cov := got.Coverage(); !cov.Probabilistic || cov.Ratio < 0.99 {
+			t.Fatalf("want probabilistic full coverage, but %+v", cov)
+		}
+		mem := got.Memory()
+		if mem.States != len(full.Visited()) || mem.VisitedBytes != int64(8*mem.States) {
+			t.Fatalf("want %d fingerprints, but %+v", len(full.Visited()), mem)
+		}
+	})
+
+	detectors := map[string]deadlock.Detector{
+		"sequential": deadlock.NewDetector(),
+		"parallel":   deadlock.NewParallelDetector(2),
+	}
+	for dname, d := range detectors {
+		t.Run("collapse/"+dname, func(t *testing.T) {
+			opts := deadlock.Options{Collapse: true}
+			got, err := d.DetectContext(context.Background(), in, opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !eqReports(got, full) {
+				t.Fatalf("want %+v, but %+v", summarize(full), summarize(got))
+			}
+			mem := got.Memory()
+			if mem.Components == 0 || mem.VisitedBytes >= full.Memory().VisitedBytes {
+				t.Fatalf("want collapsed memory less than %+v, but %+v", full.Memory(), mem)
+			}
+		})
+	}
+
+	t.Run("exclusive", func(t *testing.T) {
+		opts := deadlock.Options{HashCompaction: true, Bitstate: 64}
+		if _, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts); err == nil {
+			t.Fatalf("want error, but has no error")
+		}
+	})
+
+}
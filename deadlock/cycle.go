@@ -0,0 +1,319 @@
+package deadlock
+
+import (
+	"sort"
+)
+
+// Lasso is an infinite execution which consists of
+// a finite stem from the initial state and a loop repeated forever.
+// An empty loop means that the execution stutters at the end of the stem,
+// i.e. the system stays at the deadlocked or halting state forever.
+type Lasso interface {
+	Stem() []Transition
+	Loop() []Transition
+}
+
+type lasso struct {
+	stem []Transition
+	loop []Transition
+}
+
+func (l lasso) Stem() []Transition {
+	return l.stem
+}
+
+func (l lasso) Loop() []Transition {
+	return l.loop
+}
+
+// pathTo returns the path from the initial state to the given state
+// following the upstreams, which is the shortest one in BFS.
+func pathTo(store StateStore, id StateId) ([]Transition, error) {
+	path := []Transition{}
+	s, ok, err := store.GetState(id)
+	if err != nil || !ok {
+		return path, err
+	}
+	up := s.Upstream()
+	for up != "" {
+		// states and transitions in the path are certainly registered
+		t, _, err := store.GetTransition(up)
+		if err != nil {
+			return nil, err
+		}
+		path = append(path, t)
+		prev, _, err := store.GetState(t.Source())
+		if err != nil {
+			return nil, err
+		}
+		up = prev.Upstream()
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// edge is an edge of a graph whose nodes are numbered.
+// The via is nil if the edge is a stuttering step.
+type edge struct {
+	to  int
+	via Transition
+}
+
+// graph is a directed graph whose nodes are numbered from zero.
+type graph struct {
+	succ [][]edge
+}
+
+func (g graph) size() int {
+	return len(g.succ)
+}
+
+// stateGraph numbers the visited states in the order of their ids
+// and connects them by the transitions which the filter accepts.
+func stateGraph(visited StateSet, transited TransitionSet, accept func(Transition) bool) (graph, []StateId) {
+
+	ids := []StateId{}
+	for id := range visited {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	index := map[StateId]int{}
+	for i, id := range ids {
+		index[id] = i
+	}
+
+	ts := []Transition{}
+	for _, t := range transited {
+		ts = append(ts, t)
+	}
+	sortTransitions(ts)
+
+	g := graph{succ: make([][]edge, len(ids))}
+	for _, t := range ts {
+		to, ok := index[t.Target()]
+		if !ok || !accept(t) {
+			continue
+		}
+		from := index[t.Source()]
+		g.succ[from] = append(g.succ[from], edge{to: to, via: t})
+	}
+	return g, ids
+
+}
+
+func sortTransitions(ts []Transition) {
+	sort.Slice(ts, func(i, j int) bool { return ts[i].Id() < ts[j].Id() })
+}
+
+// livelocks finds the reachable cycles consisting of non-progress transitions,
+// which a fair scheduler could produce. It reports one lasso
+// for each strongly connected component of such cycles,
+// whose stem is the shortest one to the component.
+func livelocks(s System, store StateStore) ([]Lasso, error) {
+
+	visited, err := loadStates(store)
+	if err != nil {
+		return nil, err
+	}
+	transited, err := loadTransitions(store)
+	if err != nil {
+		return nil, err
+	}
+
+	full, ids := stateGraph(visited, transited, func(_ Transition) bool {
+		return true
+	})
+	g, _ := stateGraph(visited, transited, func(t Transition) bool {
+		return !t.Progress()
+	})
+	fc := fairnessOf(s, full, func(v int) int { return v })
+
+	all := map[int]bool{}
+	for v := 0; v < g.size(); v++ {
+		all[v] = true
+	}
+
+	found := []Lasso{}
+	for _, c := range g.fairComponents(all, fc, nil) {
+		entry := -1
+		var stem []Transition
+		for _, v := range c.nodes {
+			p, err := pathTo(store, ids[v])
+			if err != nil {
+				return nil, err
+			}
+			if entry < 0 || len(p) < len(stem) || len(p) == len(stem) && v < entry {
+				entry, stem = v, p
+			}
+		}
+		loop := g.cycleThrough(entry, members(c.nodes), c.requirements)
+		found = append(found, lasso{stem: stem, loop: transitions(loop)})
+	}
+	return found, nil
+
+}
+
+func members(scc []int) map[int]bool {
+	in := map[int]bool{}
+	for _, v := range scc {
+		in[v] = true
+	}
+	return in
+}
+
+func transitions(es []edge) []Transition {
+	ts := []Transition{}
+	for _, e := range es {
+		if e.via != nil {
+			ts = append(ts, e.via)
+		}
+	}
+	return ts
+}
+
+// stronglyConnected decomposes the graph by Tarjan's algorithm.
+// Each component is sorted in ascending order.
+func (g graph) stronglyConnected() [][]int {
+
+	index := make([]int, g.size())
+	lowlink := make([]int, g.size())
+	onStack := make([]bool, g.size())
+	for v := range index {
+		index[v] = -1
+	}
+	count := 0
+	stack := []int{}
+	sccs := [][]int{}
+
+	var connect func(v int)
+	connect = func(v int) {
+		index[v] = count
+		lowlink[v] = count
+		count++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, e := range g.succ[v] {
+			w := e.to
+			if index[w] < 0 {
+				connect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+		if lowlink[v] != index[v] {
+			return
+		}
+		scc := []int{}
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		sort.Ints(scc)
+		sccs = append(sccs, scc)
+	}
+
+	for v := 0; v < g.size(); v++ {
+		if index[v] < 0 {
+			connect(v)
+		}
+	}
+	return sccs
+
+}
+
+// cyclic tells whether the component contains at least one cycle.
+func (g graph) cyclic(scc []int) bool {
+	if len(scc) > 1 {
+		return true
+	}
+	for _, e := range g.succ[scc[0]] {
+		if e.to == scc[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// requirement is a condition which some edge in a cycle should satisfy.
+type requirement func(edge) bool
+
+// reaching requires the cycle to pass through any of the nodes.
+func reaching(nodes []int) requirement {
+	in := members(nodes)
+	return func(e edge) bool {
+		return in[e.to]
+	}
+}
+
+// pathWithin returns the shortest non-empty path inside the component
+// from the node to the end of any edge which satisfies the requirement.
+func (g graph) pathWithin(from int, in map[int]bool, req requirement) []edge {
+	parent := map[int]edge{}
+	source := map[int]int{}
+	queue := []int{from}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for _, e := range g.succ[u] {
+			v := e.to
+			if !in[v] {
+				continue
+			}
+			if req(e) {
+				path := []edge{e}
+				for w := u; w != from; w = source[w] {
+					path = append(path, parent[w])
+				}
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if _, ok := parent[v]; ok || v == from {
+				continue
+			}
+			parent[v] = e
+			source[v] = u
+			queue = append(queue, v)
+		}
+	}
+	return nil
+}
+
+// cycleThrough returns a cycle from the entry back to itself inside the component,
+// in which every requirement is satisfied by some edge.
+func (g graph) cycleThrough(entry int, in map[int]bool, reqs []requirement) []edge {
+	loop := []edge{}
+	current := entry
+	for _, req := range reqs {
+		if satisfied(loop, req) {
+			continue
+		}
+		path := g.pathWithin(current, in, req)
+		loop = append(loop, path...)
+		current = path[len(path)-1].to
+	}
+	if current == entry && len(loop) > 0 {
+		return loop
+	}
+	return append(loop, g.pathWithin(current, in, func(e edge) bool { return e.to == entry })...)
+}
+
+func satisfied(es []edge, req requirement) bool {
+	for _, e := range es {
+		if req(e) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,13 +4,19 @@
 package deadlock
 
 import (
-	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"context"
+	"fmt"
+	"time"
 )
 
 // Detector searches the state space of given system
 // and reports presence of deadlocks.
 type Detector interface {
 	Detect(s System) (Report, error)
+	// DetectContext searches the state space within the given bounds.
+	// If the search stops halfway, it returns the partial report
+	// whose StopReason tells why.
+	DetectContext(ctx context.Context, s System, opts Options) (Report, error)
 }
 
 func NewDetector() Detector {
@@ -20,102 +26,396 @@ func NewDetector() Detector {
 type detector struct{}
 
 func (d detector) Detect(s System) (Report, error) {
+	return d.DetectContext(context.Background(), s, Options{})
+}
 
-	visited := StateSet{}
-	transited := TransitionSet{}
-	accepting := StateSet{}
-	deadlocked := StateSet{}
-	traces := TransitionSet{}
+// queued is a state waiting for the expansion with its distance from the initial state.
+// The trail is recorded only in lossy searches.
+type queued struct {
+	state State
+	depth int
+	trail *trail
+}
 
-	initial := d.initialize(s)
-	queue := []State{initial}
+func (d detector) DetectContext(ctx context.Context, s System, opts Options) (Report, error) {
 
-	for len(queue) > 0 {
-		from := queue[0]
-		queue = queue[1:]
+	ctx, cancel := opts.context(ctx)
+	defer cancel()
 
-		if _, ok := visited[from.Id()]; ok {
-			continue
+	if opts.Checkpoint != "" {
+		if err := opts.checkpointable(); err != nil {
+			return report{}, err
+		}
+	}
+	ex, err := newExpander(s, opts)
+	if err != nil {
+		return report{}, err
+	}
+	if opts.Strategy != IterativeDeepening {
+		sr, err := explore(ctx, s, opts, ex, newProgress())
+		if err != nil {
+			return report{}, err
 		}
-		visited[from.Id()] = from
+		return sr.finish(s, opts, ex)
+	}
 
-		nexts := 0
-		for _, p := range s.Processes() {
-			// The locations of every processes are
-			// certainly defined inductively
-			focus, _ := from.Locations()[p.Id()]
-			for _, r := range p.Rules()[focus] {
+	if opts.Store != nil {
+		return report{}, fmt.Errorf("iterative deepening cannot reuse the state store")
+	}
+	// deepen the bound until no state is cut off by it
+	pg := newProgress()
+	for bound := 1; ; bound++ {
+		bounded := opts
+		bounded.MaxDepth = bound
+		sr, err := explore(ctx, s, bounded, ex, pg)
+		if err != nil {
+			return report{}, err
+		}
+		if sr.reason == DepthLimitReached {
+			closed, err := sr.close(ex)
+			if err != nil {
+				return report{}, err
+			}
+			if closed {
+				sr.reason = Exhausted
+			}
+		}
+		if sr.reason != DepthLimitReached || bound == opts.MaxDepth {
+			return sr.finish(s, opts, ex)
+		}
+	}
 
-				fireable, err := r.Guard()(from.SharedVars())
-				if err != nil {
-					return report{}, err
-				}
-				if !fireable {
-					continue
-				}
+}
 
-				nextLocs := map[ProcessId]rule.Location{}
-				for pid, l := range from.Locations() {
-					nextLocs[pid] = l
-				}
-				nextLocs[p.Id()] = r.Target()
+// search is an exploration of the state space.
+type search struct {
+	store      StateStore
+	queue      frontier
+	initial    StateId
+	accepting  StateSet
+	deadlocked StateSet
+	violated   map[StateId][]string
+	reason     StopReason
+	// shallowest is the minimum depth of each visited state,
+	// which is nil unless the depth-first search is bounded.
+	shallowest map[StateId]int
+	// cut is the states at the bound whose successors are not explored,
+	// which is nil unless the search is an iteration of iterative deepening.
+	cut      StateSet
+	progress *progress
+}
 
-				nextVars, err := r.Action()(from.SharedVars())
-				if err != nil {
-					return report{}, err
-				}
+// explore visits the states in the order of opts.Strategy, where
+// iterative deepening is regarded as a depth-first search.
+func explore(ctx context.Context, s System, opts Options, ex expander, pg *progress) (*search, error) {
 
-				to := state{
-					locations:  nextLocs,
-					sharedVars: nextVars,
-					upstream:   "",
-				}
+	store, err := opts.store()
+	if err != nil {
+		return nil, err
+	}
+	sr := &search{
+		store:      store,
+		queue:      newFrontier(s, opts),
+		accepting:  StateSet{},
+		deadlocked: StateSet{},
+		violated:   map[StateId][]string{},
+		reason:     Exhausted,
+		progress:   pg,
+	}
+
+	initial := ex.initial()
+	sr.initial = initial.Id()
+	sr.queue.push(queued{state: initial, depth: 0})
+	if err := sr.queue.err(); err != nil {
+		return nil, err
+	}
 
-				t := transition{
-					process: p.Id(),
-					label:   r.Label(),
-					source:  from.Id(),
-					target:  to.Id(),
+	// A depth-first search within the bound should expand a visited state
+	// again if it is reached by a shorter path, otherwise the successors
+	// cut off at the first visit would be missed.
+	if opts.Strategy != BreadthFirst && opts.MaxDepth > 0 {
+		sr.shallowest = map[StateId]int{}
+	}
+	if opts.Strategy == IterativeDeepening {
+		sr.cut = StateSet{}
+	}
+
+	if err := sr.run(ctx, s, opts, ex); err != nil {
+		return nil, err
+	}
+	return sr, nil
+
+}
+
+// run expands the queued states until the queue gets empty
+// or the search is stopped, saving checkpoints if requested.
+func (sr *search) run(ctx context.Context, s System, opts Options, ex expander) error {
+
+	store, queue := sr.store, sr.queue
+	lossy, isLossy := store.(lossyStore)
+	saved := time.Now()
+
+	for queue.len() > 0 {
+		if r, ok := interrupted(ctx); ok {
+			sr.reason = r
+			break
+		}
+		if opts.Checkpoint != "" && time.Since(saved) >= opts.checkpointInterval() {
+			if err := sr.save(s, opts, ex); err != nil {
+				return err
+			}
+			saved = time.Now()
+		}
+		sr.progress.observe(opts, store, ex.collapser, queue.len())
+
+		q := queue.pop()
+		from, depth, tr := q.state, q.depth, q.trail
+
+		seen, err := lookupState(store, from)
+		if err != nil {
+			return err
+		}
+		if seen {
+			if d, ok := sr.shallowest[from.Id()]; !ok || d <= depth {
+				continue
+			}
+		} else {
+			if !opts.allowsStates(store.CountStates()) {
+				sr.reason = StateLimitReached
+				break
+			}
+			if _, err := store.PutState(from); err != nil {
+				return err
+			}
+		}
+		if sr.shallowest != nil {
+			sr.shallowest[from.Id()] = depth
+		}
+
+		broken, err := violations(s, from)
+		if err != nil {
+			return err
+		}
+		if len(broken) > 0 {
+			sr.violated[from.Id()] = broken
+			if isLossy {
+				if err := keepTrail(lossy, ex, tr); err != nil {
+					return err
 				}
-				transited[t.Id()] = t
-				nexts++
+			}
+		}
 
-				// assume that state.Id() is independent from state.upstream
-				to.upstream = t.Id()
-				queue = append(queue, to)
+		steps, err := ex.successors(from)
+		if err != nil {
+			return err
+		}
+		if len(steps) > 0 && !opts.allowsDepth(depth) {
+			sr.reason = DepthLimitReached
+			if sr.cut != nil {
+				sr.cut[from.Id()] = from
 			}
+			continue
+		}
+		// the state reached again by a shorter path is no longer cut
+		delete(sr.cut, from.Id())
+		sr.progress.expanded(depth, len(steps))
+		nexts := []queued{}
+		for _, st := range steps {
+			if err := store.PutTransition(st.transition); err != nil {
+				return err
+			}
+			next := queued{state: st.state, depth: depth + 1}
+			if isLossy {
+				next.trail = &trail{label: st.transition.Label(), target: st.state.Id(), prev: tr}
+			}
+			nexts = append(nexts, next)
+		}
+		queue.push(nexts...)
+		if err := queue.err(); err != nil {
+			return err
 		}
 
-		if nexts == 0 {
+		if len(steps) == 0 {
 			if acceptable(s, from) {
-				accepting[from.Id()] = from
+				sr.accepting[from.Id()] = from
 				continue
 			}
-			deadlocked[from.Id()] = from
-			up := from.Upstream()
-			for up != "" {
-				// states and transitions in the path are certainly registered
-				t, _ := transited[up]
-				traces[up] = t
-				prev, _ := visited[t.Source()]
-				up = prev.Upstream()
+			sr.deadlocked[from.Id()] = from
+			if isLossy {
+				if err := keepTrail(lossy, ex, tr); err != nil {
+					return err
+				}
+			}
+			if opts.StopAtFirstDeadlock || opts.directed() {
+				sr.reason = DeadlockFound
+				break
+			}
+		}
+
+	}
+
+	if opts.Checkpoint != "" && (sr.reason == Canceled || sr.reason == TimedOut) {
+		return sr.save(s, opts, ex)
+	}
+	return nil
+
+}
+
+// keepTrail replays the trail and keeps the states and transitions on it.
+func keepTrail(lossy lossyStore, ex expander, tr *trail) error {
+	initial, steps, err := retrace(ex, tr)
+	if err != nil {
+		return err
+	}
+	lossy.keep(initial, steps)
+	return nil
+}
+
+// close tells whether every successor of the cut states has been visited,
+// i.e. the visited states are closed under the transitions,
+// in which case the transitions from the cut states are stored.
+func (sr *search) close(ex expander) (bool, error) {
+	fired := []Transition{}
+	for _, from := range sr.cut {
+		steps, err := ex.successors(from)
+		if err != nil {
+			return false, err
+		}
+		for _, st := range steps {
+			if !sr.store.HasState(st.state.Id()) {
+				return false, nil
 			}
+			fired = append(fired, st.transition)
+		}
+	}
+	for _, t := range fired {
+		if err := sr.store.PutTransition(t); err != nil {
+			return false, err
 		}
+	}
+	return true, nil
+}
+
+func (sr *search) finish(s System, opts Options, ex expander) (Report, error) {
+	return finish(s, opts, ex, sr, sr.queue.len())
+}
+
+// frontier holds the states waiting for the expansion.
+type frontier interface {
+	// push adds the successors of a state, which are popped
+	// in the given order unless the other states intervene.
+	push(...queued)
+	pop() queued
+	len() int
+	// err returns the error in ordering the states, if any.
+	err() error
+}
+
+func newFrontier(s System, opts Options) frontier {
+	switch {
+	case opts.Strategy == BreadthFirst:
+		return &fifo{}
+	case opts.directed():
+		return newPriorityQueue(s, opts)
+	}
+	return &lifo{}
+}
+
+// fifo is the queue of the breadth-first search.
+type fifo struct {
+	items []queued
+}
+
+func (f *fifo) push(qs ...queued) {
+	f.items = append(f.items, qs...)
+}
+
+func (f *fifo) pop() queued {
+	q := f.items[0]
+	f.items = f.items[1:]
+	return q
+}
+
+func (f *fifo) len() int {
+	return len(f.items)
+}
 
+func (f *fifo) err() error {
+	return nil
+}
+
+// lifo is the stack of the depth-first search.
+type lifo struct {
+	items []queued
+}
+
+func (l *lifo) push(qs ...queued) {
+	for i := len(qs) - 1; i >= 0; i-- {
+		l.items = append(l.items, qs[i])
 	}
+}
+
+func (l *lifo) pop() queued {
+	q := l.items[len(l.items)-1]
+	l.items = l.items[:len(l.items)-1]
+	return q
+}
 
-	return report{
-		visited:    visited,
-		transited:  transited,
-		initial:    initial.Id(),
-		accepting:  accepting,
-		deadlocked: deadlocked,
-		traces:     traces,
-	}, nil
+func (l *lifo) len() int {
+	return len(l.items)
+}
+
+func (l *lifo) err() error {
+	return nil
+}
+
+// finish builds the report of the search, searching livelocks if requested.
+// The frontier is the number of states left unexpanded.
+func finish(s System, opts Options, ex expander, sr *search, frontier int) (Report, error) {
+	rp, err := newReport(sr.store, sr.initial, sr.accepting, sr.deadlocked, sr.violated)
+	if err != nil {
+		return report{}, err
+	}
+	rp.stopReason = sr.reason
+	rp.coverage = exact
+	if lossy, ok := sr.store.(lossyStore); ok {
+		rp.coverage = lossy.coverage()
+	}
+	rp.reduced = opts.PartialOrder
+	rp.channels = s.Channels()
+	rp.statistics = sr.progress.statistics(sr.store, ex.collapser, frontier)
+	rp.memory = rp.statistics.Memory
+	if opts.Observer != nil {
+		opts.Observer(rp.statistics)
+	}
+	if opts.Livelock {
+		rp.livelocks, err = livelocks(s, sr.store)
+		if err != nil {
+			return report{}, err
+		}
+	}
+	return rp, nil
+}
 
+// violations returns the names of invariants which the state violates.
+func violations(s System, st State) ([]string, error) {
+	broken := []string{}
+	for _, inv := range s.Invariants() {
+		ok, symmetric, err := evaluate(s, inv.Predicate(), st)
+		if err != nil {
+			return nil, err
+		}
+		if !symmetric {
+			return nil, fmt.Errorf("asymmetric invariant: %s", inv.Name())
+		}
+		if !ok {
+			broken = append(broken, inv.Name())
+		}
+	}
+	return broken, nil
 }
 
-func (_ detector) initialize(s System) State {
+func initialize(s System) State {
 	ls := LocationSet{}
 	for _, p := range s.Processes() {
 		ls[p.Id()] = p.EntryPoint()
@@ -123,6 +423,7 @@ func (_ detector) initialize(s System) State {
 	return state{
 		locations:  ls,
 		sharedVars: s.InitVars(),
+		localVars:  initLocals(s),
 		upstream:   "",
 	}
 }
@@ -1,12 +1,16 @@
 package deadlock_test
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/y-taka-23/ddsv-go/deadlock"
 	"github.com/y-taka-23/ddsv-go/deadlock/rule"
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
 )
 
 func TestDetect(t *testing.T) {
@@ -95,6 +99,54 @@ func TestDetect(t *testing.T) {
 
 }
 
+// philosopher picks up the left fork and then the right one,
+// which deadlocks when every philosopher holds the left fork.
+func philosopher(me int, left, right vars.Name) deadlock.Process {
+	return deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").Only(when.Var(left).Is(0)).
+			Let("up_l", do.Set(me).ToVar(left)).MoveTo("1")).
+		Define(rule.At("1").Only(when.Var(right).Is(0)).
+			Let("up_r", do.Set(me).ToVar(right)).MoveTo("2")).
+		Define(rule.At("2").Only(when.Var(right).Is(me)).
+			Let("down_r", do.Set(0).ToVar(right)).MoveTo("3")).
+		Define(rule.At("3").Only(when.Var(left).Is(me)).
+			Let("down_l", do.Set(0).ToVar(left)).MoveTo("0"))
+}
+
+// politePhilosopher puts down the left fork if the right one is taken,
+// which avoids the deadlock but may livelock. Getting both forks is progress.
+func politePhilosopher(me int, left, right vars.Name) deadlock.Process {
+	return deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").Only(when.Var(left).Is(0)).
+			Let("up_l", do.Set(me).ToVar(left)).MoveTo("1")).
+		Define(rule.At("1").Only(when.Var(right).Is(0)).
+			Let("up_r", do.Set(me).ToVar(right)).MoveTo("2").MarkProgress()).
+		Define(rule.At("1").Only(when.Var(right).IsNot(0)).
+			Let("down_l", do.Set(0).ToVar(left)).MoveTo("0")).
+		Define(rule.At("2").Only(when.Var(right).Is(me)).
+			Let("down_r", do.Set(0).ToVar(right)).MoveTo("3")).
+		Define(rule.At("3").Only(when.Var(left).Is(me)).
+			Let("down_l", do.Set(0).ToVar(left)).MoveTo("0"))
+}
+
+// philosophers seats n philosophers Pi at a round table,
+// where Pi shares the fork fi on the left and f(i+1) on the right.
+func philosophers(n int, philo func(int, vars.Name, vars.Name) deadlock.Process) deadlock.System {
+	forks := vars.Shared{}
+	for i := 1; i <= n; i++ {
+		forks[vars.Name(fmt.Sprintf("f%d", i))] = 0
+	}
+	s := deadlock.NewSystem().Declare(forks)
+	for i := 1; i <= n; i++ {
+		left := vars.Name(fmt.Sprintf("f%d", i))
+		right := vars.Name(fmt.Sprintf("f%d", i%n+1))
+		s = s.Register(deadlock.ProcessId(fmt.Sprintf("P%d", i)), philo(i, left, right))
+	}
+	return s
+}
+
 type summary struct {
 	state    int
 	trans    int
@@ -114,3 +166,877 @@ func summarize(rp deadlock.Report) summary {
 		trace:    len(rp.Traces()),
 	}
 }
+
+func TestParallelDetect(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		in      deadlock.System
+		workers int
+	}{
+		{
+			"1-step 1-step",
+			deadlock.NewSystem().
+				Register("P", deadlock.NewProcess().
+					EnterAt("0").
+					Define(rule.At("0").MoveTo("1"))).
+				Register("Q", deadlock.NewProcess().
+					EnterAt("0").
+					Define(rule.At("0").MoveTo("1"))),
+			2,
+		},
+		{
+			"3 philosophers",
+			philosophers(3, philosopher),
+			4,
+		},
+		{
+			"default workers",
+			philosophers(2, philosopher),
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := deadlock.NewDetector().Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			got, err := deadlock.NewParallelDetector(tt.workers).Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !eqReports(got, want) {
+				t.Fatalf("want %+v, but %+v", summarize(want), summarize(got))
+			}
+		})
+	}
+
+}
+
+func TestParallelDetectError(t *testing.T) {
+	in := deadlock.NewSystem().
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Let("", do.Set(1).ToVar("x")).MoveTo("1")))
+	if _, err := deadlock.NewParallelDetector(2).Detect(in); err == nil {
+		t.Fatalf("want error, but has no error")
+	}
+}
+
+func eqReports(got, want deadlock.Report) bool {
+	if got.Initial() != want.Initial() {
+		return false
+	}
+	if len(got.Visited()) != len(want.Visited()) {
+		return false
+	}
+	for id, s := range want.Visited() {
+		g, ok := got.Visited()[id]
+		if !ok || g.Upstream() != s.Upstream() {
+			return false
+		}
+	}
+	return eqStateIds(got.Accepting(), want.Accepting()) &&
+		eqStateIds(got.Deadlocked(), want.Deadlocked()) &&
+		eqStateIds(got.Violated(), want.Violated()) &&
+		eqTransitionIds(got.Transited(), want.Transited()) &&
+		eqTransitionIds(got.Traces(), want.Traces())
+}
+
+func eqStateIds(got, want deadlock.StateSet) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for id := range want {
+		if _, ok := got[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func eqTransitionIds(got, want deadlock.TransitionSet) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for id := range want {
+		if _, ok := got[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDetectContext(t *testing.T) {
+
+	unbounded := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Let("incr", do.Add(1).ToVar("x")).MoveTo("0")))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		in        deadlock.System
+		opts      deadlock.Options
+		wantState int
+		want      deadlock.StopReason
+	}{
+		{
+			"exhausted", context.Background(),
+			deadlock.NewSystem().
+				Register("P", deadlock.NewProcess().
+					EnterAt("0").
+					Define(rule.At("0").MoveTo("1"))),
+			deadlock.Options{MaxStates: 2, MaxDepth: 1},
+			2, deadlock.Exhausted,
+		},
+		{
+			"state limit", context.Background(), unbounded,
+			deadlock.Options{MaxStates: 10},
+			10, deadlock.StateLimitReached,
+		},
+		{
+			"depth limit", context.Background(), unbounded,
+			deadlock.Options{MaxDepth: 5},
+			6, deadlock.DepthLimitReached,
+		},
+		{
+			"timeout", context.Background(), unbounded,
+			deadlock.Options{Timeout: 10 * time.Millisecond},
+			-1, deadlock.TimedOut,
+		},
+		{
+			"canceled", canceled, unbounded,
+			deadlock.Options{},
+			-1, deadlock.Canceled,
+		},
+	}
+
+	detectors := map[string]deadlock.Detector{
+		"sequential": deadlock.NewDetector(),
+		"parallel":   deadlock.NewParallelDetector(2),
+	}
+
+	for _, tt := range tests {
+		for dname, d := range detectors {
+			t.Run(tt.name+"/"+dname, func(t *testing.T) {
+				got, err := d.DetectContext(tt.ctx, tt.in, tt.opts)
+				if err != nil {
+					t.Fatalf("want no error, but has error %v", err)
+				}
+				if got.StopReason() != tt.want {
+					t.Fatalf("want %v, but %v", tt.want, got.StopReason())
+				}
+				if got.Complete() != (tt.want == deadlock.Exhausted) {
+					t.Fatalf("want complete %v, but %v", tt.want == deadlock.Exhausted, got.Complete())
+				}
+				if tt.wantState >= 0 && len(got.Visited()) != tt.wantState {
+					t.Fatalf("want %d states, but %d", tt.wantState, len(got.Visited()))
+				}
+			})
+		}
+	}
+
+}
+
+func TestDetectInvariant(t *testing.T) {
+
+	proc := func(guarded bool) deadlock.Process {
+		enter := rule.At("0").Let("enter", do.Set(1).ToVar("mut")).MoveTo("1")
+		if guarded {
+			enter = enter.Only(when.Var("mut").Is(0))
+		}
+		return deadlock.NewProcess().
+			EnterAt("0").
+			Define(enter).
+			Define(rule.At("1").Let("leave", do.Set(0).ToVar("mut")).MoveTo("2")).
+			HaltAt("2")
+	}
+
+	undeclared := func(_ deadlock.LocationSet, vs vars.Shared) (bool, error) {
+		if _, ok := vs["y"]; !ok {
+			return false, fmt.Errorf("undeclared variable: y")
+		}
+		return true, nil
+	}
+
+	tests := []struct {
+		name      string
+		in        deadlock.System
+		want      int
+		wantError bool
+	}{
+		{
+			"guarded",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"mut": 0}).
+				Register("P", proc(true)).
+				Register("Q", proc(true)).
+				Assert("mutex", deadlock.AtMost(1, "1")),
+			0, false,
+		},
+		{
+			"unguarded",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"mut": 0}).
+				Register("P", proc(false)).
+				Register("Q", proc(false)).
+				Assert("mutex", deadlock.AtMost(1, "1")),
+			1, false,
+		},
+		{
+			"undeclared var",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"mut": 0}).
+				Register("P", proc(true)).
+				Assert("broken", undeclared),
+			0, true,
+		},
+	}
+
+	detectors := map[string]deadlock.Detector{
+		"sequential": deadlock.NewDetector(),
+		"parallel":   deadlock.NewParallelDetector(2),
+	}
+
+	for _, tt := range tests {
+		for dname, d := range detectors {
+			t.Run(tt.name+"/"+dname, func(t *testing.T) {
+				got, err := d.Detect(tt.in)
+				if tt.wantError && err == nil {
+					t.Fatalf("want error, but has no error")
+				}
+				if !tt.wantError && err != nil {
+					t.Fatalf("want no error, but has error %v", err)
+				}
+				if tt.wantError {
+					return
+				}
+				if len(got.Violated()) != tt.want {
+					t.Fatalf("want %d violated states, but %d", tt.want, len(got.Violated()))
+				}
+				for id := range got.Violated() {
+					if len(got.Violations(id)) != 1 || got.Violations(id)[0] != "mutex" {
+						t.Fatalf("want [mutex], but %v", got.Violations(id))
+					}
+				}
+				// P.enter and Q.enter lead to the violation
+				if len(got.Traces()) != 2*tt.want {
+					t.Fatalf("want %d traces, but %d", 2*tt.want, len(got.Traces()))
+				}
+			})
+		}
+	}
+
+}
+
+func TestDetectLivelock(t *testing.T) {
+
+	tests := []struct {
+		name string
+		in   deadlock.System
+		want int
+	}{
+		{
+			"non-progress loop",
+			deadlock.NewSystem().
+				Register("P", deadlock.NewProcess().
+					EnterAt("0").
+					Define(rule.At("0").MoveTo("0"))),
+			1,
+		},
+		{
+			"progress loop",
+			deadlock.NewSystem().
+				Register("P", deadlock.NewProcess().
+					EnterAt("0").
+					Define(rule.At("0").MoveTo("0").MarkProgress())),
+			0,
+		},
+		{
+			"philosophers",
+			philosophers(2, politePhilosopher),
+			3,
+		},
+	}
+
+	detectors := map[string]deadlock.Detector{
+		"sequential": deadlock.NewDetector(),
+		"parallel":   deadlock.NewParallelDetector(2),
+	}
+
+	for _, tt := range tests {
+		for dname, d := range detectors {
+			t.Run(tt.name+"/"+dname, func(t *testing.T) {
+				got, err := d.DetectContext(context.Background(), tt.in, deadlock.Options{Livelock: true})
+				if err != nil {
+					t.Fatalf("want no error, but has error %v", err)
+				}
+				if len(got.Livelocks()) != tt.want {
+					t.Fatalf("want %d livelocks, but %d", tt.want, len(got.Livelocks()))
+				}
+				for _, l := range got.Livelocks() {
+					if !connected(got.Initial(), append(l.Stem(), l.Loop()...)) {
+						t.Fatalf("want connected lasso, but %+v", l)
+					}
+					loop := l.Loop()
+					if len(loop) == 0 || loop[0].Source() != loop[len(loop)-1].Target() {
+						t.Fatalf("want closed loop, but %+v", loop)
+					}
+					for _, tr := range loop {
+						if tr.Progress() {
+							t.Fatalf("want no progress, but %+v", tr)
+						}
+					}
+				}
+			})
+		}
+	}
+
+}
+
+func connected(from deadlock.StateId, path []deadlock.Transition) bool {
+	for _, t := range path {
+		if t.Source() != from {
+			return false
+		}
+		from = t.Target()
+	}
+	return true
+}
+
+func TestDetectLivelockFairness(t *testing.T) {
+
+	spinner := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").MoveTo("0"))
+	worker := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").Let("work", do.Nothing()).MoveTo("0").MarkProgress())
+
+	tests := []struct {
+		name string
+		in   deadlock.System
+		want int
+	}{
+		{
+			"unfair",
+			deadlock.NewSystem().
+				Register("P", spinner).
+				Register("Q", worker),
+			1,
+		},
+		{
+			"weakly fair",
+			deadlock.NewSystem().
+				Register("P", spinner).
+				Register("Q", worker.Fair(rule.Weak)),
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deadlock.NewDetector().
+				DetectContext(context.Background(), tt.in, deadlock.Options{Livelock: true})
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if len(got.Livelocks()) != tt.want {
+				t.Fatalf("want %d livelocks, but %d", tt.want, len(got.Livelocks()))
+			}
+		})
+	}
+
+}
+
+func TestDetectPartialOrder(t *testing.T) {
+
+	counter := func(x vars.Name, footprint string) deadlock.Process {
+		var incr rule.Rule
+		opaque := do.ActionFunc(func(vs vars.Shared) (vars.Shared, error) {
+			return do.Add(1).ToVar(x).Apply(vs)
+		})
+		switch footprint {
+		case "inferred":
+			incr = rule.At("0").Let("incr", do.Add(1).ToVar(x)).MoveTo("1")
+		case "declared":
+			incr = rule.At("0").Let("incr", opaque).MoveTo("1").
+				Touch(vars.Access([]vars.Name{x}, []vars.Name{x}))
+		default:
+			incr = rule.At("0").Let("incr", opaque).MoveTo("1")
+		}
+		return deadlock.NewProcess().
+			EnterAt("0").
+			Define(incr).
+			Define(rule.At("1").MoveTo("2"))
+	}
+
+	tests := []struct {
+		name string
+		in   deadlock.System
+		full summary
+		want summary
+	}{
+		{
+			"1-step 1-step",
+			deadlock.NewSystem().
+				Register("P", deadlock.NewProcess().
+					EnterAt("0").
+					Define(rule.At("0").MoveTo("1"))).
+				Register("Q", deadlock.NewProcess().
+					EnterAt("0").
+					Define(rule.At("0").MoveTo("1"))),
+			summary{state: 4, trans: 4, init: true, deadlock: 1, trace: 2},
+			summary{state: 3, trans: 2, init: true, deadlock: 1, trace: 2},
+		},
+		{
+			"declared footprints",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"x": 0, "y": 0}).
+				Register("P", counter("x", "declared")).
+				Register("Q", counter("y", "declared")),
+			summary{state: 9, trans: 12, init: true, deadlock: 1, trace: 4},
+			summary{state: 5, trans: 4, init: true, deadlock: 1, trace: 4},
+		},
+		{
+			"inferred footprints",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"x": 0, "y": 0}).
+				Register("P", counter("x", "inferred")).
+				Register("Q", counter("y", "inferred")),
+			summary{state: 9, trans: 12, init: true, deadlock: 1, trace: 4},
+			summary{state: 5, trans: 4, init: true, deadlock: 1, trace: 4},
+		},
+		{
+			"unknown footprints",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"x": 0, "y": 0}).
+				Register("P", counter("x", "unknown")).
+				Register("Q", counter("y", "unknown")),
+			summary{state: 9, trans: 12, init: true, deadlock: 1, trace: 4},
+			summary{state: 9, trans: 12, init: true, deadlock: 1, trace: 4},
+		},
+		{
+			"shared variable",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"x": 0}).
+				Register("P", counter("x", "inferred")).
+				Register("Q", counter("x", "inferred")),
+			summary{state: 9, trans: 12, init: true, deadlock: 1, trace: 4},
+			summary{state: 8, trans: 8, init: true, deadlock: 1, trace: 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full, err := deadlock.NewDetector().Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if summarize(full) != tt.full {
+				t.Fatalf("want %+v, but %+v", tt.full, summarize(full))
+			}
+			got, err := deadlock.NewDetector().
+				DetectContext(context.Background(), tt.in, deadlock.Options{PartialOrder: true})
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if summarize(got) != tt.want {
+				t.Fatalf("want %+v, but %+v", tt.want, summarize(got))
+			}
+			if !eqStateIds(got.Deadlocked(), full.Deadlocked()) {
+				t.Fatalf("want deadlocks %v, but %v", full.Deadlocked(), got.Deadlocked())
+			}
+		})
+	}
+
+}
+
+func TestDetectPartialOrderError(t *testing.T) {
+
+	proc := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").MoveTo("1"))
+
+	tests := []struct {
+		name string
+		in   deadlock.System
+		opts deadlock.Options
+	}{
+		{"livelock",
+			deadlock.NewSystem().Register("P", proc),
+			deadlock.Options{PartialOrder: true, Livelock: true}},
+		{"invariant",
+			deadlock.NewSystem().Register("P", proc).Assert("never", deadlock.AtMost(0, "1")),
+			deadlock.Options{PartialOrder: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := deadlock.NewDetector().DetectContext(context.Background(), tt.in, tt.opts); err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+			if _, err := deadlock.NewParallelDetector(2).DetectContext(context.Background(), tt.in, tt.opts); err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+		})
+	}
+
+}
+
+func TestDetectSymmetry(t *testing.T) {
+
+	stepper := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").MoveTo("1"))
+	proc := func(local vars.Name) deadlock.Process {
+		return deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("mut").Is(0)).
+				Let("lock", do.Set(1).ToVar("mut")).MoveTo("1")).
+			Define(rule.At("1").
+				Let("read", do.CopyVar("var").ToVar(local)).MoveTo("2")).
+			Define(rule.At("2").
+				Let("incr", do.Add(1).ToVar(local)).MoveTo("3")).
+			Define(rule.At("3").
+				Let("write", do.CopyVar(local).ToVar("var")).MoveTo("4")).
+			Define(rule.At("4").
+				Let("unlock", do.Set(0).ToVar("mut")).MoveTo("5")).
+			HaltAt("5")
+	}
+	mutex := vars.Shared{"var": 0, "tmp1": 0, "tmp2": 0, "mut": 0}
+
+	tests := []struct {
+		name      string
+		in        deadlock.System
+		want      summary
+		wantError bool
+	}{
+		{
+			"3 steppers",
+			deadlock.NewSystem().
+				RegisterSymmetric(
+					deadlock.NewReplica("P", stepper),
+					deadlock.NewReplica("Q", stepper),
+					deadlock.NewReplica("R", stepper)),
+			summary{state: 4, trans: 6, init: true, deadlock: 1, trace: 3},
+			false,
+		},
+		{
+			"asymmetric mutex",
+			deadlock.NewSystem().
+				Declare(mutex).
+				Register("P", proc("tmp1")).
+				Register("Q", proc("tmp2")),
+			summary{state: 21, trans: 20, init: true, deadlock: 0, trace: 0},
+			false,
+		},
+		{
+			"symmetric mutex",
+			deadlock.NewSystem().
+				Declare(mutex).
+				RegisterSymmetric(
+					deadlock.NewReplica("P", proc("tmp1"), "tmp1"),
+					deadlock.NewReplica("Q", proc("tmp2"), "tmp2")),
+			summary{state: 11, trans: 11, init: true, deadlock: 0, trace: 0},
+			false,
+		},
+		{
+			"asymmetric replicas",
+			deadlock.NewSystem().
+				Declare(mutex).
+				RegisterSymmetric(
+					deadlock.NewReplica("P", proc("tmp1"), "tmp1"),
+					deadlock.NewReplica("Q", proc("tmp2"))),
+			summary{},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deadlock.NewDetector().Detect(tt.in)
+			if tt.wantError && err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+			if !tt.wantError && err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if summarize(got) != tt.want {
+				t.Fatalf("want %+v, but %+v", tt.want, summarize(got))
+			}
+		})
+	}
+
+}
+
+func TestDetectSymmetryError(t *testing.T) {
+
+	stepper := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").MoveTo("1"))
+	// the plain system has 2 states violating it, but the symmetric one has only 1
+	qstays := func(ls deadlock.LocationSet, _ vars.Shared) (bool, error) {
+		return ls["Q"] != "1", nil
+	}
+	replicas := func(p deadlock.Process) deadlock.System {
+		return deadlock.NewSystem().
+			RegisterSymmetric(
+				deadlock.NewReplica("P", p),
+				deadlock.NewReplica("Q", p))
+	}
+
+	tests := []struct {
+		name      string
+		in        deadlock.System
+		opts      deadlock.Options
+		want      int
+		wantError bool
+	}{
+		{
+			"symmetric invariant",
+			replicas(stepper).Assert("alone", deadlock.AtMost(1, "1")),
+			deadlock.Options{},
+			1, false,
+		},
+		{
+			"process-named invariant",
+			replicas(stepper).Assert("Q stays", qstays),
+			deadlock.Options{},
+			0, true,
+		},
+		{
+			"fair process",
+			replicas(stepper.Fair(rule.Weak)),
+			deadlock.Options{Livelock: true},
+			0, true,
+		},
+		{
+			"fair rule",
+			replicas(deadlock.NewProcess().
+				EnterAt("0").
+				Define(rule.At("0").MoveTo("1").Fair(rule.Strong))),
+			deadlock.Options{Livelock: true},
+			0, true,
+		},
+		{
+			"unfair livelock",
+			replicas(stepper),
+			deadlock.Options{Livelock: true},
+			0, false,
+		},
+	}
+
+	detectors := map[string]deadlock.Detector{
+		"sequential": deadlock.NewDetector(),
+		"parallel":   deadlock.NewParallelDetector(2),
+	}
+
+	for _, tt := range tests {
+		for dname, d := range detectors {
+			t.Run(tt.name+"/"+dname, func(t *testing.T) {
+				got, err := d.DetectContext(context.Background(), tt.in, tt.opts)
+				if tt.wantError && err == nil {
+					t.Fatalf("want error, but has no error")
+				}
+				if !tt.wantError && err != nil {
+					t.Fatalf("want no error, but has error %v", err)
+				}
+				if tt.wantError {
+					return
+				}
+				if len(got.Violated()) != tt.want {
+					t.Fatalf("want %d violated states, but %d", tt.want, len(got.Violated()))
+				}
+			})
+		}
+	}
+
+}
+
+func TestStateId(t *testing.T) {
+
+	extend := do.ActionFunc(func(vs vars.Shared) (vars.Shared, error) {
+		modified := vs.Clone()
+		modified["extra"] = 1
+		return modified, nil
+	})
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Let("extend", extend).MoveTo("0")).
+			Define(rule.At("0").Let("set", do.Set(1).ToVar("x")).MoveTo("0")))
+
+	first, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	second, err := deadlock.NewParallelDetector(2).Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+
+	// x = 0 or 1, with or without extra
+	if len(first.Visited()) != 4 {
+		t.Fatalf("want 4 states, but %d", len(first.Visited()))
+	}
+	if !eqStateIds(first.Visited(), second.Visited()) {
+		t.Fatalf("want stable ids %v, but %v", first.Visited(), second.Visited())
+	}
+	for id, s := range first.Visited() {
+		if s.Id() != id || len(id) != 16 {
+			t.Fatalf("want 16-digit id of the state, but %s", id)
+		}
+	}
+
+}
+
+func TestDetectIterativeDeepeningCycles(t *testing.T) {
+
+	// both processes go around the ring forever, so every state has successors
+	ring := func(n int) deadlock.Process {
+		p := deadlock.NewProcess().EnterAt("0")
+		for i := 0; i < n; i++ {
+			p = p.Define(rule.At(rule.Location(fmt.Sprint(i))).MoveTo(rule.Location(fmt.Sprint((i + 1) % n))))
+		}
+		return p
+	}
+	in := deadlock.NewSystem().
+		Register("P", ring(9)).
+		Register("Q", ring(9))
+
+	opts := deadlock.Options{Strategy: deadlock.IterativeDeepening, MaxDepth: 80}
+	got, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if got.StopReason() != deadlock.Exhausted || len(got.Visited()) != 81 || len(got.Transited()) != 162 {
+		t.Fatalf("want every state and transition, but %v %+v", got.StopReason(), summarize(got))
+	}
+	// the deepening stops at the diameter of the state space, far before the bound
+	if n := got.Statistics().Transitions; n > 162*20 {
+		t.Fatalf("want transitions fired in at most 20 iterations, but %d", n)
+	}
+
+}
+
+func TestDetectStrategy(t *testing.T) {
+
+	// P gets stuck after 4 steps, while Q keeps cycling
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0, "y": 0}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("x").IsLessThan(4)).
+				Let("incr", do.Add(1).ToVar("x")).MoveTo("0")).
+			Define(rule.At("0").Only(when.Var("x").Is(4)).
+				Let("stop", do.Nothing()).MoveTo("1"))).
+		Register("Q", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("x").IsLessThan(4)).
+				Let("flip", do.Set(1).ToVar("y")).MoveTo("1")).
+			Define(rule.At("1").Only(when.Var("x").IsLessThan(4)).
+				Let("flop", do.Set(0).ToVar("y")).MoveTo("0")))
+
+	full, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       deadlock.Options
+		wantReason deadlock.StopReason
+	}{
+		{
+			"depth-first",
+			deadlock.Options{Strategy: deadlock.DepthFirst},
+			deadlock.Exhausted,
+		},
+		{
+			"iterative deepening",
+			deadlock.Options{Strategy: deadlock.IterativeDeepening},
+			deadlock.Exhausted,
+		},
+		{
+			"bounded iterative deepening",
+			deadlock.Options{Strategy: deadlock.IterativeDeepening, MaxDepth: 3},
+			deadlock.DepthLimitReached,
+		},
+		{
+			"breadth-first stop",
+			deadlock.Options{StopAtFirstDeadlock: true},
+			deadlock.DeadlockFound,
+		},
+		{
+			"depth-first stop",
+			deadlock.Options{Strategy: deadlock.DepthFirst, StopAtFirstDeadlock: true},
+			deadlock.DeadlockFound,
+		},
+		{
+			"iterative deepening stop",
+			deadlock.Options{Strategy: deadlock.IterativeDeepening, StopAtFirstDeadlock: true},
+			deadlock.DeadlockFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deadlock.NewDetector().DetectContext(context.Background(), in, tt.opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if got.StopReason() != tt.wantReason {
+				t.Fatalf("want %v, but %v", tt.wantReason, got.StopReason())
+			}
+			switch tt.wantReason {
+			case deadlock.Exhausted:
+				if !eqStateIds(got.Visited(), full.Visited()) ||
+					!eqStateIds(got.Deadlocked(), full.Deadlocked()) {
+					t.Fatalf("want %+v, but %+v", summarize(full), summarize(got))
+				}
+			case deadlock.DeadlockFound:
+				if len(got.Deadlocked()) != 1 {
+					t.Fatalf("want 1 deadlock, but %v", got.Deadlocked())
+				}
+				for id := range got.Deadlocked() {
+					if !replayable(got, id) {
+						t.Fatalf("want replayable trace to %s, but not", id)
+					}
+				}
+			case deadlock.DepthLimitReached:
+				if len(got.Deadlocked()) != 0 {
+					t.Fatalf("want no deadlock within the bound, but %v", got.Deadlocked())
+				}
+			}
+		})
+	}
+
+	t.Run("parallel stop", func(t *testing.T) {
+		opts := deadlock.Options{StopAtFirstDeadlock: true}
+		got, err := deadlock.NewParallelDetector(2).DetectContext(context.Background(), in, opts)
+		if err != nil {
+			t.Fatalf("want no error, but has error %v", err)
+		}
+		if got.StopReason() != deadlock.DeadlockFound || len(got.Deadlocked()) != 1 {
+			t.Fatalf("want 1 deadlock, but %v", got.Deadlocked())
+		}
+	})
+
+	t.Run("parallel depth-first", func(t *testing.T) {
+		opts := deadlock.Options{Strategy: deadlock.DepthFirst}
+		if _, err := deadlock.NewParallelDetector(2).DetectContext(context.Background(), in, opts); err == nil {
+			t.Fatalf("want error, but has no error")
+		}
+	})
+
+}
@@ -0,0 +1,425 @@
+package deadlock
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+// DiskStore is a StateStore which appends states and transitions
+// to log files in a directory. Only their payloads move to the disk,
+// and the index from their ids to the offsets in the logs stays in memory,
+// so that the memory still grows with the number of states.
+// The BFS frontier is also kept in memory. Revisited states are read back
+// from the log to detect collisions of their ids.
+type DiskStore interface {
+	StateStore
+	// Err returns the first error in the store. Report.LoadVisited
+	// and Report.LoadTransited also report the errors in reading it.
+	Err() error
+	// Close closes the log files. The report of the search
+	// reads the store lazily, so close it after using the report.
+	Close() error
+}
+
+// NewDiskStore creates the log files in the directory,
+// truncating the ones left by previous searches.
+func NewDiskStore(dir string) (DiskStore, error) {
+	states, err := createLog(filepath.Join(dir, "states.log"))
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := createLog(filepath.Join(dir, "transitions.log"))
+	if err != nil {
+		states.file.Close()
+		return nil, err
+	}
+	return &diskStore{states: states, transitions: transitions}, nil
+}
+
+type diskStore struct {
+	mu          sync.Mutex
+	states      *logFile
+	transitions *logFile
+	err         error
+	// indexBytes is the size of the index of the states, see measure
+	indexBytes int64
+}
+
+func (ds *diskStore) fail(err error) error {
+	if err != nil && ds.err == nil {
+		ds.err = err
+	}
+	return err
+}
+
+func (ds *diskStore) PutState(s State) (bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ok, err := ds.states.append(string(s.Id()), encodeState(s))
+	if ok {
+		ds.indexBytes += int64(len(s.Id())) + 16
+	}
+	return ok, ds.fail(err)
+}
+
+func (ds *diskStore) HasState(id StateId) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	_, ok := ds.states.index[string(id)]
+	return ok
+}
+
+func (ds *diskStore) GetState(id StateId) (State, bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	body, ok, err := ds.states.read(string(id))
+	if err != nil || !ok {
+		return nil, false, ds.fail(err)
+	}
+	s, err := decodeState(body)
+	if err != nil {
+		return nil, false, ds.fail(err)
+	}
+	return s, true, nil
+}
+
+func (ds *diskStore) CountStates() int {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return len(ds.states.index)
+}
+
+func (ds *diskStore) EachState(f func(State) error) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.fail(ds.states.each(func(body []byte) error {
+		s, err := decodeState(body)
+		if err != nil {
+			return err
+		}
+		return f(s)
+	}))
+}
+
+func (ds *diskStore) PutTransition(t Transition) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	_, err := ds.transitions.append(string(t.Id()), encodeTransition(t))
+	return ds.fail(err)
+}
+
+func (ds *diskStore) GetTransition(id TransitionId) (Transition, bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	body, ok, err := ds.transitions.read(string(id))
+	if err != nil || !ok {
+		return nil, false, ds.fail(err)
+	}
+	t, err := decodeTransition(body)
+	if err != nil {
+		return nil, false, ds.fail(err)
+	}
+	return t, true, nil
+}
+
+func (ds *diskStore) EachTransition(f func(Transition) error) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.fail(ds.transitions.each(func(body []byte) error {
+		t, err := decodeTransition(body)
+		if err != nil {
+			return err
+		}
+		return f(t)
+	}))
+}
+
+func (ds *diskStore) Err() error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.err
+}
+
+func (ds *diskStore) Close() error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	err := ds.states.close()
+	if e := ds.transitions.close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// span locates a record body in the log file.
+type span struct {
+	offset int64
+	length int
+}
+
+// logFile is an append-only sequence of length-prefixed records
+// with the in-memory index from their keys.
+type logFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+	index  map[string]span
+}
+
+func createLog(path string) (*logFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &logFile{
+		file:   f,
+		writer: bufio.NewWriter(f),
+		index:  map[string]span{},
+	}, nil
+}
+
+func (lf *logFile) append(key string, body []byte) (bool, error) {
+	if _, ok := lf.index[key]; ok {
+		return false, nil
+	}
+	header := appendUvarint(nil, uint64(len(body)))
+	if _, err := lf.writer.Write(header); err != nil {
+		return false, err
+	}
+	if _, err := lf.writer.Write(body); err != nil {
+		return false, err
+	}
+	lf.index[key] = span{offset: lf.size + int64(len(header)), length: len(body)}
+	lf.size += int64(len(header) + len(body))
+	return true, nil
+}
+
+func (lf *logFile) read(key string) ([]byte, bool, error) {
+	sp, ok := lf.index[key]
+	if !ok {
+		return nil, false, nil
+	}
+	// the record may be still in the buffer
+	if sp.offset+int64(sp.length) > lf.size-int64(lf.writer.Buffered()) {
+		if err := lf.writer.Flush(); err != nil {
+			return nil, false, err
+		}
+	}
+	body := make([]byte, sp.length)
+	if _, err := lf.file.ReadAt(body, sp.offset); err != nil {
+		return nil, false, err
+	}
+	return body, true, nil
+}
+
+func (lf *logFile) each(f func([]byte) error) error {
+	if err := lf.writer.Flush(); err != nil {
+		return err
+	}
+	r := bufio.NewReader(io.NewSectionReader(lf.file, 0, lf.size))
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return err
+		}
+		if err := f(body); err != nil {
+			return err
+		}
+	}
+}
+
+func (lf *logFile) close() error {
+	err := lf.writer.Flush()
+	if e := lf.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func encodeState(s State) []byte {
+	buf := appendString(nil, string(s.Id()))
+	buf = appendString(buf, string(s.Upstream()))
+	pids := []ProcessId{}
+	for pid := range s.Locations() {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+	buf = appendUvarint(buf, uint64(len(pids)))
+	for _, pid := range pids {
+		buf = appendString(buf, string(pid))
+		buf = appendString(buf, string(s.Locations()[pid]))
+	}
+	xs := []vars.Name{}
+	for x := range s.SharedVars() {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	buf = appendUvarint(buf, uint64(len(xs)))
+	for _, x := range xs {
+		buf = appendString(buf, string(x))
+		buf = appendVarint(buf, int64(s.SharedVars()[x]))
+	}
+	lids := []ProcessId{}
+	for pid := range s.LocalVars() {
+		lids = append(lids, pid)
+	}
+	sort.Slice(lids, func(i, j int) bool { return lids[i] < lids[j] })
+	buf = appendUvarint(buf, uint64(len(lids)))
+	for _, pid := range lids {
+		buf = appendString(buf, string(pid))
+		vs := s.LocalVars()[pid]
+		ys := []vars.Name{}
+		for y := range vs {
+			ys = append(ys, y)
+		}
+		sort.Slice(ys, func(i, j int) bool { return ys[i] < ys[j] })
+		buf = appendUvarint(buf, uint64(len(ys)))
+		for _, y := range ys {
+			buf = appendString(buf, string(y))
+			buf = appendVarint(buf, int64(vs[y]))
+		}
+	}
+	return buf
+}
+
+func decodeState(body []byte) (State, error) {
+	d := decoder{buf: body}
+	s := state{
+		id:         StateId(d.string()),
+		upstream:   TransitionId(d.string()),
+		locations:  LocationSet{},
+		sharedVars: vars.Shared{},
+		localVars:  LocalVarSet{},
+	}
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		pid := ProcessId(d.string())
+		s.locations[pid] = rule.Location(d.string())
+	}
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		x := vars.Name(d.string())
+		s.sharedVars[x] = int(d.varint())
+	}
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		pid := ProcessId(d.string())
+		vs := vars.Shared{}
+		for m := d.uvarint(); m > 0 && d.err == nil; m-- {
+			y := vars.Name(d.string())
+			vs[y] = int(d.varint())
+		}
+		s.localVars[pid] = vs
+	}
+	return s, d.err
+}
+
+func encodeTransition(t Transition) []byte {
+	return appendTransition(appendString(nil, string(t.Id())), t)
+}
+
+// appendTransition writes the fields of the transition except for its id.
+func appendTransition(buf []byte, t Transition) []byte {
+	buf = appendString(buf, string(t.Process()))
+	buf = appendString(buf, string(t.Label()))
+	buf = appendString(buf, string(t.Source()))
+	buf = appendString(buf, string(t.Target()))
+	progress := uint64(0)
+	if t.Progress() {
+		progress = 1
+	}
+	buf = appendUvarint(buf, progress)
+	// the rule is kept to judge fairness
+	ref, partners := ruleRef{}, []participant{}
+	if tr, ok := t.(transition); ok {
+		ref, partners = tr.rule, tr.partners
+	}
+	buf = appendString(buf, string(ref.source))
+	buf = appendUvarint(buf, uint64(ref.index))
+	buf = appendUvarint(buf, uint64(len(partners)))
+	for _, p := range partners {
+		buf = appendString(buf, string(p.process))
+		buf = appendString(buf, string(p.label))
+		buf = appendString(buf, string(p.rule.source))
+		buf = appendUvarint(buf, uint64(p.rule.index))
+	}
+	return buf
+}
+
+func decodeTransition(body []byte) (Transition, error) {
+	d := decoder{buf: body}
+	t := transition{
+		id:       TransitionId(d.string()),
+		process:  ProcessId(d.string()),
+		label:    rule.Label(d.string()),
+		source:   StateId(d.string()),
+		target:   StateId(d.string()),
+		progress: d.uvarint() == 1,
+	}
+	t.rule = ruleRef{source: rule.Location(d.string()), index: int(d.uvarint())}
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		p := participant{process: ProcessId(d.string()), label: rule.Label(d.string())}
+		p.rule = ruleRef{source: rule.Location(d.string()), index: int(d.uvarint())}
+		t.partners = append(t.partners, p)
+	}
+	return t, d.err
+}
+
+// decoder reads the values written by appendUvarint, appendVarint and appendString.
+// After the first error, it returns only zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = fmt.Errorf("corrupt record in the store")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return x
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = fmt.Errorf("corrupt record in the store")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return x
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if uint64(len(d.buf)) < n {
+		d.err = fmt.Errorf("corrupt record in the store")
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
@@ -0,0 +1,157 @@
+package deadlock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+// encoder serializes states into compact byte vectors.
+// Locations are interned per process and variables are laid out
+// in fixed slots, both of which are determined only by the system,
+// so that the same state is encoded identically in any search order.
+type encoder struct {
+	processes []ProcessId
+	locations []map[rule.Location]uint64
+	slots     []vars.Name
+	// locals are the slots of the local variables of each process
+	locals [][]vars.Name
+}
+
+func newEncoder(s System) encoder {
+
+	enc := encoder{
+		processes: []ProcessId{},
+		locations: []map[rule.Location]uint64{},
+		slots:     []vars.Name{},
+	}
+
+	for _, p := range s.Processes() {
+		ls := []rule.Location{p.EntryPoint()}
+		ls = append(ls, p.HaltingPoints()...)
+		for l, rs := range p.Rules() {
+			ls = append(ls, l)
+			for _, r := range rs {
+				ls = append(ls, r.Target())
+			}
+		}
+		sort.Slice(ls, func(i, j int) bool { return ls[i] < ls[j] })
+		index := map[rule.Location]uint64{}
+		for _, l := range ls {
+			if _, ok := index[l]; !ok {
+				index[l] = uint64(len(index))
+			}
+		}
+		enc.processes = append(enc.processes, p.Id())
+		enc.locations = append(enc.locations, index)
+		enc.locals = append(enc.locals, localNames(p))
+	}
+
+	for x := range s.InitVars() {
+		enc.slots = append(enc.slots, x)
+	}
+	sort.Slice(enc.slots, func(i, j int) bool { return enc.slots[i] < enc.slots[j] })
+
+	return enc
+
+}
+
+// encode writes the interned locations of processes and the values of variables.
+func (enc encoder) encode(st state) []byte {
+	buf := make([]byte, 0, binary.MaxVarintLen64*(len(enc.processes)+len(enc.slots)+1))
+	buf = enc.appendLocations(buf, st.locations)
+	buf = enc.appendVars(buf, st.sharedVars)
+	return enc.appendLocals(buf, st.localVars)
+}
+
+func (enc encoder) appendLocations(buf []byte, ls LocationSet) []byte {
+	for i, pid := range enc.processes {
+		l := ls[pid]
+		if n, ok := enc.locations[i][l]; ok {
+			buf = appendUvarint(buf, n+1)
+			continue
+		}
+		buf = appendUvarint(buf, 0)
+		buf = appendString(buf, string(l))
+	}
+	return buf
+}
+
+// appendVars writes the values in the declared slots. If the variables
+// differ from the declared ones, e.g. a user-defined action adds
+// a new variable, they are written with their names after a marker.
+func (enc encoder) appendVars(buf []byte, vs vars.Shared) []byte {
+	if enc.declares(vs) {
+		buf = append(buf, 0)
+		for _, x := range enc.slots {
+			buf = appendVarint(buf, int64(vs[x]))
+		}
+		return buf
+	}
+	buf = append(buf, 1)
+	xs := []vars.Name{}
+	for x := range vs {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	for _, x := range xs {
+		buf = appendString(buf, string(x))
+		buf = appendVarint(buf, int64(vs[x]))
+	}
+	return buf
+}
+
+// appendLocals writes the values of local variables in the declared slots,
+// since the rules cannot add any local variable.
+func (enc encoder) appendLocals(buf []byte, locals LocalVarSet) []byte {
+	for i, pid := range enc.processes {
+		for _, x := range enc.locals[i] {
+			buf = appendVarint(buf, int64(locals[pid][x]))
+		}
+	}
+	return buf
+}
+
+func (enc encoder) declares(vs vars.Shared) bool {
+	if len(vs) != len(enc.slots) {
+		return false
+	}
+	for _, x := range enc.slots {
+		if _, ok := vs[x]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+func appendVarint(buf []byte, x int64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// identify hashes the encoded state by 64-bit FNV-1a,
+// so that the id depends only on the state, not on the search order.
+// Distinct states of the same id are detected by the lookup in the store,
+// see lookupState.
+func (enc encoder) identify(st state) state {
+	h := fnv.New64a()
+	h.Write(enc.encode(st))
+	st.id = StateId(fmt.Sprintf("%016x", h.Sum64()))
+	return st
+}
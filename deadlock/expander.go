@@ -0,0 +1,414 @@
+package deadlock
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+// step is a pair of a fired transition and the state it reaches.
+type step struct {
+	transition Transition
+	state      State
+}
+
+// expander computes the successors of states in a search.
+type expander struct {
+	system  System
+	encoder encoder
+	// persistent tells whether the rules of the process at the location
+	// are independent from every rule of the other processes,
+	// which is nil if the partial order reduction is disabled.
+	persistent map[ProcessId]map[rule.Location]bool
+	// collapser is nil unless the components of states are collapsed.
+	collapser *collapser
+	// alphabets are the processes which synchronize on each event.
+	alphabets map[rule.Event][]ProcessId
+}
+
+func newExpander(s System, opts Options) (expander, error) {
+	if err := validateSymmetries(s); err != nil {
+		return expander{}, err
+	}
+	if err := validateChannels(s); err != nil {
+		return expander{}, err
+	}
+	if err := validateEvents(s); err != nil {
+		return expander{}, err
+	}
+	if err := validateLocals(s); err != nil {
+		return expander{}, err
+	}
+	if opts.PartialOrder && opts.Livelock {
+		return expander{}, fmt.Errorf("partial order reduction cannot find livelocks")
+	}
+	if opts.PartialOrder && len(s.Invariants()) > 0 {
+		return expander{}, fmt.Errorf("partial order reduction cannot check invariants")
+	}
+	if len(s.Symmetries()) > 0 && opts.Livelock && hasFairness(s) {
+		return expander{}, fmt.Errorf("symmetry reduction cannot find livelocks under fairness")
+	}
+	ex := expander{system: s, encoder: newEncoder(s), alphabets: alphabets(s)}
+	if opts.Collapse {
+		ex.collapser = newCollapser()
+	}
+	if opts.PartialOrder {
+		ex.persistent = persistentLocations(s)
+	}
+	return ex, nil
+}
+
+func validateSymmetries(s System) error {
+	for _, group := range s.Symmetries() {
+		for _, r := range group {
+			if len(r.Owned()) != len(group[0].Owned()) ||
+				!sameNames(localNames(r.Process()), localNames(group[0].Process())) {
+				return fmt.Errorf("asymmetric replicas: %s and %s", group[0].Id(), r.Id())
+			}
+			for _, x := range r.Owned() {
+				if _, ok := s.InitVars()[x]; !ok {
+					return fmt.Errorf("undeclared variable: %s", x)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// initial returns the canonical initial state of the system.
+func (ex expander) initial() State {
+	return ex.reach(initialize(ex.system).(state))
+}
+
+// reach canonicalizes, identifies and collapses the reached state.
+func (ex expander) reach(st state) state {
+	st = ex.encoder.identify(ex.canonical(st))
+	if ex.collapser != nil {
+		st = ex.collapser.collapse(ex.encoder, st)
+	}
+	return st
+}
+
+// member is the part of a state which a replica determines.
+// The values are of the owned variables followed by the local ones.
+type member struct {
+	location rule.Location
+	values   []int
+	locals   vars.Shared
+}
+
+func (m member) less(n member) bool {
+	if m.location != n.location {
+		return m.location < n.location
+	}
+	for i := range m.values {
+		if m.values[i] != n.values[i] {
+			return m.values[i] < n.values[i]
+		}
+	}
+	return false
+}
+
+// canonical sorts the replicas of each symmetric group by their locations,
+// owned variables and local variables, which chooses a representative
+// among the states equivalent under permutations of the replicas.
+func (ex expander) canonical(st state) state {
+
+	if len(ex.system.Symmetries()) == 0 {
+		return st
+	}
+	locs := LocationSet{}
+	for pid, l := range st.locations {
+		locs[pid] = l
+	}
+	vs := st.sharedVars.Clone()
+	locals := LocalVarSet{}
+	for pid, ls := range st.localVars {
+		locals[pid] = ls
+	}
+
+	for _, group := range ex.system.Symmetries() {
+		ys := localNames(group[0].Process())
+		ms := make([]member, len(group))
+		for i, r := range group {
+			ms[i] = member{location: locs[r.Id()], values: []int{}, locals: locals[r.Id()]}
+			for _, x := range r.Owned() {
+				ms[i].values = append(ms[i].values, vs[x])
+			}
+			for _, y := range ys {
+				ms[i].values = append(ms[i].values, locals[r.Id()][y])
+			}
+		}
+		sort.SliceStable(ms, func(i, j int) bool { return ms[i].less(ms[j]) })
+		for i, r := range group {
+			locs[r.Id()] = ms[i].location
+			for k, x := range r.Owned() {
+				vs[x] = ms[i].values[k]
+			}
+			if len(ys) > 0 {
+				locals[r.Id()] = ms[i].locals
+			}
+		}
+	}
+
+	st.locations = locs
+	st.sharedVars = vs
+	st.localVars = locals
+	return st
+
+}
+
+// evaluate evaluates the predicate at the state. If the system has symmetries,
+// the predicate is also evaluated at every state obtained by permuting
+// the replicas, and it reports false as the second result
+// unless the predicate agrees on all of them, since the reduction
+// cannot tell the states apart.
+func evaluate(s System, p Predicate, st State) (bool, bool, error) {
+	ok, err := p(st.Locations(), st.SharedVars())
+	if err != nil {
+		return false, false, err
+	}
+	for _, o := range orbit(s, st.Locations(), st.SharedVars()) {
+		other, err := p(o.locations, o.sharedVars)
+		if err != nil {
+			return false, false, err
+		}
+		if other != ok {
+			return ok, false, nil
+		}
+	}
+	return ok, true, nil
+}
+
+// image is the part of a state which predicates observe.
+type image struct {
+	locations  LocationSet
+	sharedVars vars.Shared
+}
+
+// orbit returns the images obtained by permuting the replicas
+// of each symmetric group, including the given one.
+func orbit(s System, ls LocationSet, vs vars.Shared) []image {
+	images := []image{{locations: ls, sharedVars: vs}}
+	for _, group := range s.Symmetries() {
+		next := []image{}
+		for _, v := range images {
+			for _, perm := range permutations(len(group)) {
+				locs := LocationSet{}
+				for pid, l := range v.locations {
+					locs[pid] = l
+				}
+				ws := v.sharedVars.Clone()
+				for i, r := range group {
+					src := group[perm[i]]
+					locs[r.Id()] = v.locations[src.Id()]
+					for k, x := range r.Owned() {
+						ws[x] = v.sharedVars[src.Owned()[k]]
+					}
+				}
+				next = append(next, image{locations: locs, sharedVars: ws})
+			}
+		}
+		images = next
+	}
+	return images
+}
+
+// permutations returns every permutation of 0, 1, ..., n-1.
+func permutations(n int) [][]int {
+	if n == 0 {
+		return [][]int{{}}
+	}
+	perms := [][]int{}
+	for _, p := range permutations(n - 1) {
+		for i := 0; i <= len(p); i++ {
+			q := make([]int, 0, n)
+			q = append(q, p[:i]...)
+			q = append(q, n-1)
+			q = append(q, p[i:]...)
+			perms = append(perms, q)
+		}
+	}
+	return perms
+}
+
+// persistentLocations finds the locations where the process
+// only fires rules independent from the others. The transitions
+// of the process at such a location form a persistent set, namely
+// no execution of the other processes can affect or be affected by them.
+func persistentLocations(s System) map[ProcessId]map[rule.Location]bool {
+
+	persistent := map[ProcessId]map[rule.Location]bool{}
+	for _, p := range s.Processes() {
+		persistent[p.Id()] = map[rule.Location]bool{}
+		for l, rs := range p.Rules() {
+			persistent[p.Id()][l] = independentOthers(s, p.Id(), rs)
+		}
+	}
+	return persistent
+
+}
+
+func independentOthers(s System, pid ProcessId, rs []rule.Rule) bool {
+	for _, r := range rs {
+		f, ok := r.Footprint()
+		if !ok {
+			return false
+		}
+		for _, q := range s.Processes() {
+			if q.Id() == pid {
+				continue
+			}
+			for _, others := range q.Rules() {
+				for _, o := range others {
+					g, ok := o.Footprint()
+					if !ok || !vars.Independent(f, g) {
+						return false
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// successors fires every fireable rule at the given state.
+// The steps are ordered by the registration of processes and rules,
+// which makes the search deterministic. If the partial order reduction
+// is enabled, only the steps of the first process at a persistent
+// location are returned. If the system has symmetric groups,
+// the reached states are canonicalized.
+func (ex expander) successors(from State) ([]step, error) {
+
+	steps := []step{}
+	for _, p := range ex.system.Processes() {
+		ps, err := ex.fire(p, from)
+		if err != nil {
+			return nil, err
+		}
+		if len(ps) > 0 && ex.persistent[p.Id()][from.Locations()[p.Id()]] {
+			return ps, nil
+		}
+		steps = append(steps, ps...)
+	}
+	return steps, nil
+
+}
+
+// fire fires the fireable rules of the process.
+// Sending rules on unbuffered channels are fired together with their receivers,
+// and rules on events together with the other processes synchronizing on them.
+func (ex expander) fire(p Process, from State) ([]step, error) {
+
+	steps := []step{}
+	// The locations of every processes are
+	// certainly defined inductively
+	focus, _ := from.Locations()[p.Id()]
+	for i, r := range p.Rules()[focus] {
+		ref := ruleRef{source: focus, index: i}
+		if _, ok := r.Event(); ok {
+			ss, err := ex.synchronize(p, from, ref)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, ss...)
+			continue
+		}
+		if ex.unbuffered(r, rule.Sending) {
+			ss, err := ex.rendezvous(p, from, ref)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, ss...)
+			continue
+		}
+		st, ok, err := ex.fireRule(p, from, ref)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			steps = append(steps, st)
+		}
+	}
+	return steps, nil
+
+}
+
+// unbuffered tells whether the rule communicates in the direction over an unbuffered channel.
+func (ex expander) unbuffered(r rule.Rule, dir rule.Direction) bool {
+	c, ok := r.Communication()
+	return ok && c.Direction == dir && ex.system.Channels()[c.Channel] == 0
+}
+
+// fireRule fires the referred rule of the process if it is fireable.
+// Rules on unbuffered channels or events are never fireable alone.
+func (ex expander) fireRule(p Process, from State, ref ruleRef) (step, bool, error) {
+
+	r := p.Rules()[ref.source][ref.index]
+	if _, ok := r.Event(); ok {
+		return step{}, false, nil
+	}
+	vs := view(from.SharedVars(), from.LocalVars(), p.Id())
+	fireable, err := r.Guard().Test(vs)
+	if err != nil || !fireable {
+		return step{}, false, err
+	}
+
+	if c, ok := r.Communication(); ok {
+		capacity := ex.system.Channels()[c.Channel]
+		if capacity == 0 {
+			return step{}, false, nil
+		}
+		vs, ok, err = communicate(c, capacity, vs)
+		if err != nil || !ok {
+			return step{}, false, err
+		}
+	}
+
+	vs, err = r.Action().Apply(vs)
+	if err != nil {
+		return step{}, false, err
+	}
+	nextVars, nextLocals := split(vs, from.LocalVars(), p.Id())
+
+	t := transition{
+		process:  p.Id(),
+		label:    r.Label(),
+		progress: r.IsProgress(),
+		rule:     ref,
+	}
+	moves := map[ProcessId]rule.Location{p.Id(): r.Target()}
+	return ex.move(from, moves, nextVars, nextLocals, t), true, nil
+
+}
+
+// move reaches the state where the processes have moved and the variables are updated,
+// and completes the transition from the given state.
+func (ex expander) move(from State, moves map[ProcessId]rule.Location, nextVars vars.Shared, nextLocals LocalVarSet, t transition) step {
+
+	nextLocs := map[ProcessId]rule.Location{}
+	for pid, l := range from.Locations() {
+		nextLocs[pid] = l
+	}
+	for pid, l := range moves {
+		nextLocs[pid] = l
+	}
+
+	to := ex.reach(state{
+		locations:  nextLocs,
+		sharedVars: nextVars,
+		localVars:  nextLocals,
+		upstream:   "",
+	})
+
+	t.source = from.Id()
+	t.target = to.Id()
+	t = t.identify()
+
+	// state.Id() is independent from state.upstream
+	to.upstream = t.Id()
+	return step{transition: t, state: to}
+
+}
@@ -0,0 +1,284 @@
+package deadlock
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+// Minimize reduces the trace to the deadlocked state in the report
+// by removing the transitions irrelevant to the deadlock.
+// A transition is removed if the rest of the trace still fires the same rules
+// and ends at a deadlocked state where every process is at the same location,
+// though the values of shared variables may differ from the original ones.
+// The result is 1-minimal, i.e. no more transition can be removed alone.
+// Systems with symmetric processes are not supported.
+func Minimize(s System, rp Report, id StateId) ([]Transition, error) {
+
+	if len(s.Symmetries()) > 0 {
+		return nil, fmt.Errorf("minimization does not support symmetric processes")
+	}
+	target, ok := rp.Deadlocked()[id]
+	if !ok {
+		return nil, fmt.Errorf("not a deadlocked state: %s", id)
+	}
+	trace := rp.TraceTo(id)
+	if len(trace) == 0 {
+		return trace, nil
+	}
+
+	ex, err := newExpander(s, Options{})
+	if err != nil {
+		return nil, err
+	}
+	// the trace is replayed once to be renumbered by the fresh expander
+	trace, ok, err = replay(ex, trace, target.Locations())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("trace to %s cannot be replayed", id)
+	}
+
+	// later transitions are tried first since they tend to be
+	// depended on by fewer transitions
+	for i := len(trace) - 1; i >= 0; i-- {
+		candidate := append(append([]Transition{}, trace[:i]...), trace[i+1:]...)
+		replayed, ok, err := replay(ex, candidate, target.Locations())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			trace = replayed
+		}
+	}
+	return trace, nil
+
+}
+
+// replay fires the rules of the transitions in order from the initial state.
+// It reports false if a rule is not fireable on the way,
+// or the reached state is not deadlocked at the given locations.
+func replay(ex expander, trace []Transition, locs map[ProcessId]rule.Location) ([]Transition, bool, error) {
+
+	replayed := []Transition{}
+	current := ex.initial()
+	for _, t := range trace {
+		tr, ok := t.(transition)
+		if !ok {
+			return nil, false, fmt.Errorf("unknown transition: %s", t.Id())
+		}
+		st, ok, err := ex.fireTransition(current, tr)
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		replayed = append(replayed, st.transition)
+		current = st.state
+	}
+
+	for pid, l := range locs {
+		if current.Locations()[pid] != l {
+			return nil, false, nil
+		}
+	}
+	steps, err := ex.successors(current)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(steps) > 0 || acceptable(ex.system, current) {
+		return nil, false, nil
+	}
+	return replayed, true, nil
+
+}
+
+// Explanation tells why no process can move at a state.
+type Explanation struct {
+	State     State
+	Processes []ProcessExplanation
+}
+
+// ProcessExplanation is the situation of a process at the state.
+type ProcessExplanation struct {
+	Process  ProcessId
+	Location rule.Location
+	// Halting tells whether the location is a halting point.
+	Halting bool
+	Rules   []RuleExplanation
+}
+
+// RuleExplanation is the situation of an outgoing rule at the state.
+type RuleExplanation struct {
+	Label  rule.Label
+	Target rule.Location
+	// Guard is the human-readable description of the guard.
+	Guard string
+	// Reads is the values of variables which the guard reads,
+	// which is nil if the guard does not know its footprint.
+	Reads vars.Shared
+	// Communication describes the channel operation, e.g. "ch!1", if any.
+	Communication string
+	// Channel tells why the channel blocks the rule, e.g. "ch is full",
+	// which is empty if the channel is ready or the rule does not communicate.
+	Channel string
+	// Event is the synchronization event of the rule, if any.
+	Event rule.Event
+	// Sync tells which processes the rule waits for on the event, e.g. "Q not ready for e",
+	// which is empty if every participant is ready or the rule does not synchronize.
+	Sync     string
+	Fireable bool
+}
+
+// Explain lists, per process, the location and the outgoing rules at the state
+// with their guards, which shows which guard blocks the process at a deadlock.
+func Explain(s System, st State) (Explanation, error) {
+
+	exp := Explanation{State: st}
+	as := alphabets(s)
+	for _, p := range s.Processes() {
+		focus, ok := st.Locations()[p.Id()]
+		if !ok {
+			return Explanation{}, fmt.Errorf("unknown process: %s", p.Id())
+		}
+		pe := ProcessExplanation{Process: p.Id(), Location: focus}
+		for _, l := range p.HaltingPoints() {
+			if l == focus {
+				pe.Halting = true
+			}
+		}
+		vs := view(st.SharedVars(), st.LocalVars(), p.Id())
+		for _, r := range p.Rules()[focus] {
+			fireable, err := r.Guard().Test(vs)
+			if err != nil {
+				return Explanation{}, err
+			}
+			re := RuleExplanation{
+				Label:    r.Label(),
+				Target:   r.Target(),
+				Guard:    when.Describe(r.Guard()),
+				Fireable: fireable,
+			}
+			if c, ok := r.Communication(); ok {
+				re.Communication = c.String()
+				blocked, err := channelBlocked(s, st, p.Id(), c)
+				if err != nil {
+					return Explanation{}, err
+				}
+				re.Channel = blocked
+				re.Fireable = fireable && blocked == ""
+			}
+			if e, ok := r.Event(); ok {
+				re.Event = e
+				blocked, err := eventBlocked(s, as[e], st, p.Id(), e)
+				if err != nil {
+					return Explanation{}, err
+				}
+				re.Sync = blocked
+				re.Fireable = fireable && blocked == ""
+			}
+			if f, ok := when.FootprintOf(r.Guard()); ok {
+				re.Reads = vars.Shared{}
+				for _, x := range f.Reads() {
+					re.Reads[x] = vs[x]
+				}
+			}
+			pe.Rules = append(pe.Rules, re)
+		}
+		exp.Processes = append(exp.Processes, pe)
+	}
+	return exp, nil
+
+}
+
+// channelBlocked tells why the channel blocks the communication of the process,
+// or returns an empty string if the channel is ready.
+func channelBlocked(s System, st State, pid ProcessId, c rule.Communication) (string, error) {
+
+	capacity := s.Channels()[c.Channel]
+	if capacity > 0 {
+		length := st.SharedVars()[lengthVar(c.Channel)]
+		if c.Direction == rule.Sending && length >= capacity {
+			return fmt.Sprintf("%s is full", c.Channel), nil
+		}
+		if c.Direction == rule.Receiving && length == 0 {
+			return fmt.Sprintf("%s is empty", c.Channel), nil
+		}
+		return "", nil
+	}
+
+	for _, q := range s.Processes() {
+		if q.Id() == pid {
+			continue
+		}
+		for _, o := range q.Rules()[st.Locations()[q.Id()]] {
+			d, ok := o.Communication()
+			if !ok || d.Channel != c.Channel || d.Direction == c.Direction {
+				continue
+			}
+			fireable, err := o.Guard().Test(view(st.SharedVars(), st.LocalVars(), q.Id()))
+			if err != nil {
+				return "", err
+			}
+			if fireable {
+				return "", nil
+			}
+		}
+	}
+	if c.Direction == rule.Sending {
+		return fmt.Sprintf("no receiver on %s", c.Channel), nil
+	}
+	return fmt.Sprintf("no sender on %s", c.Channel), nil
+
+}
+
+func (e Explanation) String() string {
+	b := strings.Builder{}
+	for _, pe := range e.Processes {
+		fmt.Fprintf(&b, "%s at %s", pe.Process, pe.Location)
+		switch {
+		case pe.Halting:
+			b.WriteString(": halting")
+		case len(pe.Rules) == 0:
+			b.WriteString(": no rule")
+		}
+		b.WriteString("\n")
+		for _, re := range pe.Rules {
+			status := "blocked"
+			if re.Fireable {
+				status = "fireable"
+			}
+			fmt.Fprintf(&b, "  %s -> %s when %s", re.Label, re.Target, re.Guard)
+			if re.Communication != "" {
+				fmt.Fprintf(&b, " and %s", re.Communication)
+			}
+			if re.Event != "" {
+				fmt.Fprintf(&b, " on %s", re.Event)
+			}
+			fmt.Fprintf(&b, ": %s", status)
+			if re.Channel != "" {
+				fmt.Fprintf(&b, " (%s)", re.Channel)
+			}
+			if re.Sync != "" {
+				fmt.Fprintf(&b, " (%s)", re.Sync)
+			}
+			if len(re.Reads) > 0 {
+				xs := []vars.Name{}
+				for x := range re.Reads {
+					xs = append(xs, x)
+				}
+				sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+				vals := []string{}
+				for _, x := range xs {
+					vals = append(vals, fmt.Sprintf("%s = %d", x, re.Reads[x]))
+				}
+				fmt.Fprintf(&b, " (%s)", strings.Join(vals, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package deadlock_test
+
+import (
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+// P takes x and waits for z forever, while Q logs into y until x is taken
+var stuck = deadlock.NewSystem().
+	Declare(vars.Shared{"x": 0, "y": 0, "z": 0}).
+	Register("P", deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").Only(when.Var("x").Is(0)).
+			Let("take", do.Set(1).ToVar("x")).MoveTo("1")).
+		Define(rule.At("1").Only(when.Var("z").Is(1)).
+			Let("wait", do.Nothing()).MoveTo("2")).
+		HaltAt("2")).
+	Register("Q", deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").Only(when.Var("x").Is(0)).
+			Let("log", do.Set(1).ToVar("y")).MoveTo("0")))
+
+func TestMinimize(t *testing.T) {
+
+	rp, err := deadlock.NewDetector().Detect(stuck)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if len(rp.Deadlocked()) != 2 {
+		t.Fatalf("want 2 deadlocks, but %v", rp.Deadlocked())
+	}
+
+	for id, st := range rp.Deadlocked() {
+		got, err := deadlock.Minimize(stuck, rp, id)
+		if err != nil {
+			t.Fatalf("want no error, but has error %v", err)
+		}
+		if len(got) != 1 || got[0].Process() != "P" || got[0].Label() != "take" {
+			t.Fatalf("want only P's take from %v, but %v", rp.TraceTo(id), got)
+		}
+		if !connected(rp.Initial(), got) {
+			t.Fatalf("want path from the initial state, but %v", got)
+		}
+		if _, ok := rp.Deadlocked()[got[0].Target()]; !ok {
+			t.Fatalf("want path to a deadlock, but to %s", got[0].Target())
+		}
+		if st.SharedVars()["y"] == 1 && len(rp.TraceTo(id)) != 2 {
+			t.Fatalf("want the original trace with Q's log, but %v", rp.TraceTo(id))
+		}
+	}
+
+	if _, err := deadlock.Minimize(stuck, rp, rp.Initial()); err == nil {
+		t.Fatalf("want error for the non-deadlocked state, but has no error")
+	}
+
+}
+
+func TestExplain(t *testing.T) {
+
+	rp, err := deadlock.NewDetector().Detect(stuck)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+
+	for _, st := range rp.Deadlocked() {
+		got, err := deadlock.Explain(stuck, st)
+		if err != nil {
+			t.Fatalf("want no error, but has error %v", err)
+		}
+		want := []deadlock.ProcessExplanation{
+			{Process: "P", Location: "1", Rules: []deadlock.RuleExplanation{
+				{Label: "wait", Target: "2", Guard: "z == 1", Reads: vars.Shared{"z": 0}},
+			}},
+			{Process: "Q", Location: "0", Rules: []deadlock.RuleExplanation{
+				{Label: "log", Target: "0", Guard: "x == 0", Reads: vars.Shared{"x": 1}},
+			}},
+		}
+		if len(got.Processes) != len(want) {
+			t.Fatalf("want %+v, but %+v", want, got.Processes)
+		}
+		for i, pe := range got.Processes {
+			w := want[i]
+			if pe.Process != w.Process || pe.Location != w.Location || pe.Halting ||
+				len(pe.Rules) != 1 || pe.Rules[0].Label != w.Rules[0].Label ||
+				pe.Rules[0].Target != w.Rules[0].Target || pe.Rules[0].Guard != w.Rules[0].Guard ||
+				pe.Rules[0].Fireable || !eqVars(pe.Rules[0].Reads, w.Rules[0].Reads) {
+				t.Fatalf("want %+v, but %+v", w, pe)
+			}
+		}
+
+		wantText := "P at 1\n" +
+			"  wait -> 2 when z == 1: blocked (z = 0)\n" +
+			"Q at 0\n" +
+			"  log -> 0 when x == 0: blocked (x = 1)\n"
+		if got.String() != wantText {
+			t.Fatalf("want %q, but %q", wantText, got.String())
+		}
+	}
+
+	got, err := deadlock.Explain(stuck, rp.Visited()[rp.Initial()])
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	for _, pe := range got.Processes {
+		if !pe.Rules[0].Fireable {
+			t.Fatalf("want fireable at the initial state, but %+v", pe)
+		}
+	}
+
+}
+
+func eqVars(xs, ys vars.Shared) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for x, v := range xs {
+		if w, ok := ys[x]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
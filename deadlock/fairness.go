@@ -0,0 +1,218 @@
+package deadlock
+
+import (
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+)
+
+// constraint is a fairness assumption on a process or a rule.
+type constraint struct {
+	strong bool
+	fires  func(Transition) bool
+}
+
+// fairness holds the constraints declared in the system
+// and tells whether each of them is fireable at the nodes of a graph.
+type fairness struct {
+	constraints []constraint
+	enabled     func(v int, c int) bool
+}
+
+// fairnessOf collects the constraints declared on processes and rules.
+// The full graph contains every transition between the system states,
+// and the node maps the nodes of the checked graph to the system states.
+func fairnessOf(s System, full graph, state func(v int) int) fairness {
+
+	cs := []constraint{}
+	for _, p := range s.Processes() {
+		pid := p.Id()
+		if p.Fairness() != rule.Unfair {
+			cs = append(cs, constraint{
+				strong: p.Fairness() == rule.Strong,
+				fires: func(t Transition) bool {
+					return involves(t, pid)
+				},
+			})
+		}
+		for l, rs := range p.Rules() {
+			for i, r := range rs {
+				if r.Fairness() == rule.Unfair {
+					continue
+				}
+				ref := ruleRef{source: l, index: i}
+				cs = append(cs, constraint{
+					strong: r.Fairness() == rule.Strong,
+					fires: func(t Transition) bool {
+						return firesRule(t, pid, ref)
+					},
+				})
+			}
+		}
+	}
+
+	return fairness{
+		constraints: cs,
+		enabled: func(v int, c int) bool {
+			for _, e := range full.succ[state(v)] {
+				if e.via != nil && cs[c].fires(e.via) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+}
+
+// hasFairness tells whether some process or rule of the system is fair.
+func hasFairness(s System) bool {
+	for _, p := range s.Processes() {
+		if p.Fairness() != rule.Unfair {
+			return true
+		}
+		for _, rs := range p.Rules() {
+			for _, r := range rs {
+				if r.Fairness() != rule.Unfair {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// fairComponent is a strongly connected component which admits a fair cycle
+// satisfying the requirements.
+type fairComponent struct {
+	nodes        []int
+	requirements []requirement
+}
+
+// fairComponents finds the components of the subgraph induced by the nodes,
+// each of which admits a cycle visiting every acceptance set
+// and respecting the fairness constraints. Following Emerson and Lei,
+// the nodes where a strongly fair rule is fireable but never fired
+// are removed and the rest is decomposed again.
+func (g graph) fairComponents(in map[int]bool, fc fairness, acceptance [][]int) []fairComponent {
+
+	sub := graph{succ: make([][]edge, g.size())}
+	for v := range in {
+		for _, e := range g.succ[v] {
+			if in[e.to] {
+				sub.succ[v] = append(sub.succ[v], e)
+			}
+		}
+	}
+
+	found := []fairComponent{}
+	for _, scc := range sub.stronglyConnected() {
+		if !in[scc[0]] || !sub.cyclic(scc) {
+			continue
+		}
+		ms := members(scc)
+
+		fired := make([]bool, len(fc.constraints))
+		for _, v := range scc {
+			for _, e := range sub.succ[v] {
+				for c, con := range fc.constraints {
+					if ms[e.to] && e.via != nil && con.fires(e.via) {
+						fired[c] = true
+					}
+				}
+			}
+		}
+
+		unfired := map[int]bool{}
+		for c, con := range fc.constraints {
+			if !con.strong || fired[c] {
+				continue
+			}
+			for _, v := range scc {
+				if fc.enabled(v, c) {
+					unfired[v] = true
+				}
+			}
+		}
+		if len(unfired) > 0 {
+			rest := map[int]bool{}
+			for _, v := range scc {
+				if !unfired[v] {
+					rest[v] = true
+				}
+			}
+			found = append(found, g.fairComponents(rest, fc, acceptance)...)
+			continue
+		}
+
+		reqs := []requirement{}
+		fair := true
+		for c, con := range fc.constraints {
+			if fired[c] {
+				reqs = append(reqs, firing(con))
+				continue
+			}
+			disabled := []int{}
+			for _, v := range scc {
+				if !fc.enabled(v, c) {
+					disabled = append(disabled, v)
+				}
+			}
+			if len(disabled) == 0 {
+				fair = false
+				break
+			}
+			reqs = append(reqs, reaching(disabled))
+		}
+		for _, acc := range acceptance {
+			visits := []int{}
+			for _, v := range acc {
+				if ms[v] {
+					visits = append(visits, v)
+				}
+			}
+			if len(visits) == 0 {
+				fair = false
+				break
+			}
+			reqs = append(reqs, reaching(visits))
+		}
+		if fair {
+			found = append(found, fairComponent{nodes: scc, requirements: reqs})
+		}
+	}
+	return found
+
+}
+
+// firing requires the cycle to fire the constrained rules.
+func firing(con constraint) requirement {
+	return func(e edge) bool {
+		return e.via != nil && con.fires(e.via)
+	}
+}
+
+// involves tells whether the process takes part in the transition.
+func involves(t Transition, pid ProcessId) bool {
+	for _, p := range t.Processes() {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// firesRule tells whether the transition fires the rule of the process.
+func firesRule(t Transition, pid ProcessId, ref ruleRef) bool {
+	tr, ok := t.(transition)
+	if !ok {
+		return false
+	}
+	if tr.process == pid && tr.rule == ref {
+		return true
+	}
+	for _, p := range tr.partners {
+		if p.process == pid && p.rule == ref {
+			return true
+		}
+	}
+	return false
+}
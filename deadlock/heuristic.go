@@ -0,0 +1,139 @@
+package deadlock
+
+import (
+	"container/heap"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+)
+
+// Heuristic estimates how far the state is from deadlocks.
+// States of smaller estimates are visited first in directed searches.
+type Heuristic func(State) (int, error)
+
+// EnabledProcesses counts the processes which can fire some rule at the state,
+// so that the states where more processes are blocked are visited first.
+// A rule on a channel or an event is enabled only if the channel
+// or the other participants are ready, as Explain tells.
+func EnabledProcesses(s System) Heuristic {
+	as := alphabets(s)
+	return func(st State) (int, error) {
+		n := 0
+		for _, p := range s.Processes() {
+			for _, r := range p.Rules()[st.Locations()[p.Id()]] {
+				ok, err := enabled(s, as, st, p.Id(), r)
+				if err != nil {
+					return 0, err
+				}
+				if ok {
+					n++
+					break
+				}
+			}
+		}
+		return n, nil
+	}
+}
+
+// enabled tells whether the guard of the rule holds
+// and neither the channel nor the event of the rule blocks it.
+func enabled(s System, as map[rule.Event][]ProcessId, st State, pid ProcessId, r rule.Rule) (bool, error) {
+	ok, err := r.Guard().Test(view(st.SharedVars(), st.LocalVars(), pid))
+	if err != nil || !ok {
+		return false, err
+	}
+	blocked := ""
+	if c, ok := r.Communication(); ok {
+		blocked, err = channelBlocked(s, st, pid, c)
+	}
+	if e, ok := r.Event(); ok {
+		blocked, err = eventBlocked(s, as[e], st, pid, e)
+	}
+	return blocked == "", err
+}
+
+// prioritized is a queued state with its priority in directed searches,
+// where the ties are broken by the order of arrival.
+type prioritized struct {
+	queued
+	priority int
+	arrival  int
+}
+
+// priorityQueue is the frontier of directed searches.
+type priorityQueue struct {
+	items    []prioritized
+	score    func(queued) (int, error)
+	arrivals int
+	failure  error
+}
+
+func newPriorityQueue(s System, opts Options) *priorityQueue {
+	h := opts.Heuristic
+	if h == nil {
+		h = EnabledProcesses(s)
+	}
+	pq := &priorityQueue{}
+	pq.score = func(q queued) (int, error) {
+		n, err := h(q.state)
+		if opts.Strategy == AStar {
+			n += q.depth
+		}
+		return n, err
+	}
+	return pq
+}
+
+// push evaluates the heuristic, whose first error is kept for err.
+func (pq *priorityQueue) push(qs ...queued) {
+	for _, q := range qs {
+		n, err := pq.score(q)
+		if err != nil {
+			if pq.failure == nil {
+				pq.failure = err
+			}
+			continue
+		}
+		heap.Push((*prioritizedHeap)(pq), prioritized{queued: q, priority: n, arrival: pq.arrivals})
+		pq.arrivals++
+	}
+}
+
+func (pq *priorityQueue) pop() queued {
+	return heap.Pop((*prioritizedHeap)(pq)).(prioritized).queued
+}
+
+func (pq *priorityQueue) len() int {
+	return len(pq.items)
+}
+
+func (pq *priorityQueue) err() error {
+	return pq.failure
+}
+
+// prioritizedHeap implements heap.Interface on the priority queue.
+type prioritizedHeap priorityQueue
+
+func (h *prioritizedHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *prioritizedHeap) Less(i, j int) bool {
+	if h.items[i].priority != h.items[j].priority {
+		return h.items[i].priority < h.items[j].priority
+	}
+	return h.items[i].arrival < h.items[j].arrival
+}
+
+func (h *prioritizedHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *prioritizedHeap) Push(x interface{}) {
+	h.items = append(h.items, x.(prioritized))
+}
+
+func (h *prioritizedHeap) Pop() interface{} {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
@@ -0,0 +1,140 @@
+package deadlock_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+func TestDetectHeuristic(t *testing.T) {
+
+	// P gets stuck after 8 steps, while Q keeps cycling
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0, "y": 0}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("x").IsLessThan(8)).
+				Let("incr", do.Add(1).ToVar("x")).MoveTo("0"))).
+		Register("Q", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("x").IsLessThan(8)).
+				Let("flip", do.Set(1).ToVar("y")).MoveTo("1")).
+			Define(rule.At("1").Only(when.Var("x").IsLessThan(8)).
+				Let("flop", do.Set(0).ToVar("y")).MoveTo("0")))
+
+	bfs, err := deadlock.NewDetector().DetectContext(
+		context.Background(), in, deadlock.Options{StopAtFirstDeadlock: true})
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+
+	remaining := func(s deadlock.State) (int, error) {
+		return 8 - s.SharedVars()["x"], nil
+	}
+
+	tests := []struct {
+		name string
+		opts deadlock.Options
+	}{
+		{
+			"best-first by enabled processes",
+			deadlock.Options{Strategy: deadlock.BestFirst},
+		},
+		{
+			"best-first by remaining steps",
+			deadlock.Options{Strategy: deadlock.BestFirst, Heuristic: remaining},
+		},
+		{
+			"A* by remaining steps",
+			deadlock.Options{Strategy: deadlock.AStar, Heuristic: remaining},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deadlock.NewDetector().DetectContext(context.Background(), in, tt.opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if got.StopReason() != deadlock.DeadlockFound || len(got.Deadlocked()) != 1 {
+				t.Fatalf("want 1 deadlock, but %v", got.Deadlocked())
+			}
+			for id := range got.Deadlocked() {
+				if !replayable(got, id) {
+					t.Fatalf("want replayable trace to %s, but not", id)
+				}
+			}
+			if len(got.Visited()) > len(bfs.Visited()) {
+				t.Fatalf("want at most %d states, but %d", len(bfs.Visited()), len(got.Visited()))
+			}
+		})
+	}
+
+	t.Run("heuristic error", func(t *testing.T) {
+		opts := deadlock.Options{
+			Strategy: deadlock.BestFirst,
+			Heuristic: func(s deadlock.State) (int, error) {
+				return 0, fmt.Errorf("failed")
+			},
+		}
+		if _, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts); err == nil {
+			t.Fatalf("want error, but has no error")
+		}
+	})
+
+}
+
+func TestEnabledProcesses(t *testing.T) {
+
+	step := func(r rule.Rule) deadlock.Process {
+		return deadlock.NewProcess().
+			EnterAt("0").
+			Define(r.MoveTo("1"))
+	}
+
+	tests := []struct {
+		name string
+		in   deadlock.System
+		want int
+	}{
+		{"guards", deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0}).
+			Register("P", step(rule.At("0").Only(when.Var("x").Is(0)))).
+			Register("Q", step(rule.At("0").Only(when.Var("x").Is(1)))).
+			Register("R", step(rule.At("0"))), 2},
+		{"channels", deadlock.NewSystem().
+			Channel("buf", 1).
+			Channel("unbuf", 0).
+			Register("P", step(rule.At("0").Receive("buf", ""))).
+			Register("Q", step(rule.At("0").Send("unbuf", 1))).
+			Register("R", step(rule.At("0").Send("buf", 1))), 1},
+		{"events", deadlock.NewSystem().
+			Register("P", step(rule.At("0").Sync("e"))).
+			Register("Q", step(rule.At("1").Sync("e"))).
+			Register("R", step(rule.At("0").Sync("f"))).
+			Register("S", step(rule.At("0").Sync("f"))), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp, err := deadlock.NewDetector().Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			got, err := deadlock.EnabledProcesses(tt.in)(rp.Visited()[rp.Initial()])
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("want %d, but %d", tt.want, got)
+			}
+		})
+	}
+
+}
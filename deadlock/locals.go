@@ -0,0 +1,90 @@
+package deadlock
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+func validateLocals(s System) error {
+	for _, p := range s.Processes() {
+		for x := range p.LocalVars() {
+			if _, ok := s.InitVars()[x]; ok {
+				return fmt.Errorf("local variable %s of %s shadows the shared one", x, p.Id())
+			}
+		}
+	}
+	return nil
+}
+
+// localNames returns the names of the local variables of the process in order.
+func localNames(p Process) []vars.Name {
+	ys := []vars.Name{}
+	for y := range p.LocalVars() {
+		ys = append(ys, y)
+	}
+	sort.Slice(ys, func(i, j int) bool { return ys[i] < ys[j] })
+	return ys
+}
+
+func sameNames(xs, ys []vars.Name) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if xs[i] != ys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// initLocals collects the initial values of the local variables.
+func initLocals(s System) LocalVarSet {
+	locals := LocalVarSet{}
+	for _, p := range s.Processes() {
+		if len(p.LocalVars()) > 0 {
+			locals[p.Id()] = p.LocalVars().Clone()
+		}
+	}
+	return locals
+}
+
+// view merges the shared variables and the local variables of the process,
+// which the guards and the actions of the process see.
+func view(shared vars.Shared, locals LocalVarSet, pid ProcessId) vars.Shared {
+	mine := locals[pid]
+	if len(mine) == 0 {
+		return shared
+	}
+	vs := shared.Clone()
+	for x, n := range mine {
+		vs[x] = n
+	}
+	return vs
+}
+
+// split separates the variables seen by the process into the shared ones
+// and the local ones, the latter of which replace the ones of the process.
+// The given set is not modified.
+func split(vs vars.Shared, locals LocalVarSet, pid ProcessId) (vars.Shared, LocalVarSet) {
+	declared := locals[pid]
+	if len(declared) == 0 {
+		return vs, locals
+	}
+	shared, mine := vars.Shared{}, vars.Shared{}
+	for x, n := range vs {
+		if _, ok := declared[x]; ok {
+			mine[x] = n
+		} else {
+			shared[x] = n
+		}
+	}
+	next := LocalVarSet{}
+	for p, ls := range locals {
+		next[p] = ls
+	}
+	next[pid] = mine
+	return shared, next
+}
@@ -0,0 +1,150 @@
+package deadlock_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+// incrementer adds one to the global variable through a temporary
+// without any lock, which loses updates
+func incrementer(global, tmp vars.Name) deadlock.Process {
+	return deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").Let("read", do.CopyVar(global).ToVar(tmp)).MoveTo("1")).
+		Define(rule.At("1").Let("incr", do.Add(1).ToVar(tmp)).MoveTo("2")).
+		Define(rule.At("2").Let("write", do.CopyVar(tmp).ToVar(global)).MoveTo("3")).
+		HaltAt("3")
+}
+
+func TestLocalVars(t *testing.T) {
+
+	local := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		Register("P", incrementer("x", "tmp").Declare(vars.Shared{"tmp": 0})).
+		Register("Q", incrementer("x", "tmp").Declare(vars.Shared{"tmp": 0}))
+	shared := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0, "tmp1": 0, "tmp2": 0}).
+		Register("P", incrementer("x", "tmp1")).
+		Register("Q", incrementer("x", "tmp2"))
+
+	got, err := deadlock.NewDetector().Detect(local)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	want, err := deadlock.NewDetector().Detect(shared)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if len(got.Visited()) != len(want.Visited()) || len(got.Transited()) != len(want.Transited()) {
+		t.Fatalf("want %+v, but %+v", summarize(want), summarize(got))
+	}
+
+	finals := map[int]bool{}
+	for _, st := range got.Accepting() {
+		if _, ok := st.SharedVars()["tmp"]; ok {
+			t.Fatalf("want no local variable in shared ones, but %v", st.SharedVars())
+		}
+		if st.LocalVars()["P"]["tmp"] == 0 || st.LocalVars()["Q"]["tmp"] == 0 {
+			t.Fatalf("want local variables written, but %v", st.LocalVars())
+		}
+		finals[st.SharedVars()["x"]] = true
+	}
+	if !finals[1] || !finals[2] || len(finals) != 2 {
+		t.Fatalf("want the lost update, but %v", finals)
+	}
+
+	dir, err := ioutil.TempDir("", "ddsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := deadlock.NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stored, err := deadlock.NewDetector().DetectContext(context.Background(), local, deadlock.Options{Store: store})
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if !eqReports(stored, got) {
+		t.Fatalf("want %+v, but %+v", summarize(got), summarize(stored))
+	}
+	for id, st := range stored.Accepting() {
+		if !eqVars(st.LocalVars()["P"], got.Accepting()[id].LocalVars()["P"]) {
+			t.Fatalf("want %v, but %v", got.Accepting()[id].LocalVars(), st.LocalVars())
+		}
+	}
+
+	var out bytes.Buffer
+	if _, err := deadlock.NewPrinter(&out).Print(got); err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if !strings.Contains(out.String(), "P @ 0 (tmp = 0), Q @ 0 (tmp = 0)\\nx = 0") {
+		t.Fatalf("want local variables next to locations, but %s", out.String())
+	}
+
+}
+
+func TestLocalVarsSymmetric(t *testing.T) {
+
+	proc := func() deadlock.Process {
+		return incrementer("x", "tmp").Declare(vars.Shared{"tmp": 0})
+	}
+	plain, err := deadlock.NewDetector().Detect(deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		Register("P", proc()).
+		Register("Q", proc()))
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	reduced, err := deadlock.NewDetector().Detect(deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		RegisterSymmetric(deadlock.NewReplica("P", proc()), deadlock.NewReplica("Q", proc())))
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if len(reduced.Visited()) >= len(plain.Visited()) {
+		t.Fatalf("want fewer states than %d, but %d", len(plain.Visited()), len(reduced.Visited()))
+	}
+
+}
+
+func TestLocalVarsError(t *testing.T) {
+
+	tests := []struct {
+		name string
+		in   deadlock.System
+	}{
+		{"shadowing", deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0, "tmp": 0}).
+			Register("P", incrementer("x", "tmp").Declare(vars.Shared{"tmp": 0}))},
+		{"other's local", deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0}).
+			Register("P", incrementer("x", "tmp").Declare(vars.Shared{"tmp": 0})).
+			Register("Q", incrementer("x", "tmp"))},
+		{"asymmetric locals", deadlock.NewSystem().
+			Declare(vars.Shared{"x": 0}).
+			RegisterSymmetric(
+				deadlock.NewReplica("P", incrementer("x", "tmp").Declare(vars.Shared{"tmp": 0})),
+				deadlock.NewReplica("Q", incrementer("x", "tmp").Declare(vars.Shared{"tmp": 0, "y": 0})))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := deadlock.NewDetector().Detect(tt.in); err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+		})
+	}
+
+}
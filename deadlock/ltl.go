@@ -0,0 +1,240 @@
+package deadlock
+
+import (
+	"fmt"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/ltl"
+)
+
+// CheckLTL verifies that every execution in the complete report of the system
+// satisfies the formula, whose atomic propositions are interpreted by the given predicates.
+// An execution which reaches a state without successors is regarded as
+// staying at the state forever. Executions which the fairness assumptions
+// of the system exclude are not taken into account.
+// It returns a counterexample if the formula is violated, or nil if the formula holds.
+func CheckLTL(s System, rp Report, f ltl.Formula, props map[string]Predicate) (Lasso, error) {
+
+	if !rp.Complete() {
+		return nil, fmt.Errorf("incomplete report: %s", rp.StopReason())
+	}
+	if rp.Coverage().Probabilistic {
+		return nil, fmt.Errorf("incomplete report: probabilistic search")
+	}
+	if rp.Reduced() {
+		return nil, fmt.Errorf("incomplete report: partial order reduction")
+	}
+	if len(s.Symmetries()) > 0 && hasFairness(s) {
+		return nil, fmt.Errorf("symmetry reduction cannot check fairness")
+	}
+	for _, name := range ltl.Propositions(f) {
+		if _, ok := props[name]; !ok {
+			return nil, fmt.Errorf("undefined proposition: %s", name)
+		}
+	}
+
+	visited, err := rp.LoadVisited()
+	if err != nil {
+		return nil, err
+	}
+	transited, err := rp.LoadTransited()
+	if err != nil {
+		return nil, err
+	}
+	g, ids := stateGraph(visited, transited, func(_ Transition) bool {
+		return true
+	})
+	for v := range g.succ {
+		if len(g.succ[v]) == 0 {
+			g.succ[v] = []edge{{to: v, via: nil}}
+		}
+	}
+
+	pd := product{
+		system:    s,
+		report:    rp,
+		visited:   visited,
+		ids:       ids,
+		props:     props,
+		automaton: ltl.Translate(ltl.Not(f)),
+		index:     map[pair]int{},
+		cache:     map[fact]bool{},
+	}
+	if err := pd.explore(g, rp.Initial()); err != nil {
+		return nil, err
+	}
+	fc := fairnessOf(s, g, func(v int) int { return pd.pairs[v].state })
+	return pd.acceptingLasso(fc), nil
+
+}
+
+// pair is a node of the product, which consists of
+// the number of a system state and the id of an automaton node.
+type pair struct {
+	state int
+	node  int
+}
+
+// fact is a cache key of the truth value of the proposition at the state.
+type fact struct {
+	state int
+	prop  string
+}
+
+type product struct {
+	system    System
+	report    Report
+	visited   StateSet
+	ids       []StateId
+	props     map[string]Predicate
+	automaton ltl.Automaton
+	pairs     []pair
+	index     map[pair]int
+	parent    []edge
+	graph     graph
+	cache     map[fact]bool
+}
+
+// explore constructs the reachable part of the product in BFS order,
+// so that the smaller number a pair has, the shorter its stem is.
+func (pd *product) explore(g graph, initial StateId) error {
+
+	start := 0
+	for v, id := range pd.ids {
+		if id == initial {
+			start = v
+		}
+	}
+
+	for _, n := range pd.automaton.Nodes() {
+		if !n.Initial() {
+			continue
+		}
+		ok, err := pd.labeled(start, n)
+		if err != nil {
+			return err
+		}
+		if ok {
+			pd.add(pair{state: start, node: n.Id()}, edge{to: -1})
+		}
+	}
+
+	for i := 0; i < len(pd.pairs); i++ {
+		from := pd.pairs[i]
+		for _, e := range g.succ[from.state] {
+			for _, m := range pd.automaton.Nodes()[from.node].Successors() {
+				ok, err := pd.labeled(e.to, pd.automaton.Nodes()[m])
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
+				to := pair{state: e.to, node: m}
+				j, ok := pd.index[to]
+				if !ok {
+					j = pd.add(to, edge{to: i, via: e.via})
+				}
+				pd.graph.succ[i] = append(pd.graph.succ[i], edge{to: j, via: e.via})
+			}
+		}
+	}
+	return nil
+
+}
+
+// add registers the pair with the edge from its parent,
+// whose to field points the parent inversely.
+func (pd *product) add(p pair, up edge) int {
+	j := len(pd.pairs)
+	pd.pairs = append(pd.pairs, p)
+	pd.index[p] = j
+	pd.parent = append(pd.parent, up)
+	pd.graph.succ = append(pd.graph.succ, nil)
+	return j
+}
+
+// labeled tells whether the state satisfies the literals of the automaton node.
+func (pd *product) labeled(v int, n ltl.Node) (bool, error) {
+	for _, name := range n.Positive() {
+		ok, err := pd.holds(v, name)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	for _, name := range n.Negative() {
+		ok, err := pd.holds(v, name)
+		if err != nil || ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (pd *product) holds(v int, name string) (bool, error) {
+	key := fact{state: v, prop: name}
+	if ok, cached := pd.cache[key]; cached {
+		return ok, nil
+	}
+	s := pd.visited[pd.ids[v]]
+	ok, symmetric, err := evaluate(pd.system, pd.props[name], s)
+	if err != nil {
+		return false, err
+	}
+	if !symmetric {
+		return false, fmt.Errorf("asymmetric proposition: %s", name)
+	}
+	pd.cache[key] = ok
+	return ok, nil
+}
+
+// acceptingLasso finds a reachable fair cycle which visits every acceptance set,
+// choosing the one with the shortest stem.
+func (pd *product) acceptingLasso(fc fairness) Lasso {
+
+	acceptance := [][]int{}
+	for _, acc := range pd.automaton.Acceptance() {
+		set := []int{}
+		for v, p := range pd.pairs {
+			if contains(acc, p.node) {
+				set = append(set, v)
+			}
+		}
+		acceptance = append(acceptance, set)
+	}
+
+	all := map[int]bool{}
+	for v := range pd.pairs {
+		all[v] = true
+	}
+
+	var found Lasso
+	best := -1
+	for _, c := range pd.graph.fairComponents(all, fc, acceptance) {
+		entry := c.nodes[0]
+		if best >= 0 && entry > best {
+			continue
+		}
+		stem := []Transition{}
+		for v := entry; pd.parent[v].to >= 0; v = pd.parent[v].to {
+			if pd.parent[v].via != nil {
+				stem = append(stem, pd.parent[v].via)
+			}
+		}
+		for i, j := 0, len(stem)-1; i < j; i, j = i+1, j-1 {
+			stem[i], stem[j] = stem[j], stem[i]
+		}
+		loop := pd.graph.cycleThrough(entry, members(c.nodes), c.requirements)
+		found, best = lasso{stem: stem, loop: transitions(loop)}, entry
+	}
+	return found
+
+}
+
+func contains(xs []int, x int) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,287 @@
+package ltl
+
+import (
+	"sort"
+)
+
+// Automaton is a generalized Büchi automaton whose nodes are labeled by literals.
+// An infinite run is accepted if it visits each acceptance set infinitely often.
+type Automaton interface {
+	Nodes() []Node
+	// Acceptance returns the acceptance sets of node ids.
+	// If there is no acceptance set, every infinite run is accepted.
+	Acceptance() [][]int
+}
+
+// Node is a state of the automaton. When the automaton is at the node,
+// the Positive propositions must hold and the Negative ones must not.
+type Node interface {
+	Id() int
+	Initial() bool
+	Positive() []string
+	Negative() []string
+	Successors() []int
+}
+
+type automaton struct {
+	nodes      []Node
+	acceptance [][]int
+}
+
+func (a automaton) Nodes() []Node {
+	return a.nodes
+}
+
+func (a automaton) Acceptance() [][]int {
+	return a.acceptance
+}
+
+type node struct {
+	id         int
+	initial    bool
+	positive   []string
+	negative   []string
+	successors []int
+}
+
+func (n node) Id() int {
+	return n.id
+}
+
+func (n node) Initial() bool {
+	return n.initial
+}
+
+func (n node) Positive() []string {
+	return n.positive
+}
+
+func (n node) Negative() []string {
+	return n.negative
+}
+
+func (n node) Successors() []int {
+	return n.successors
+}
+
+// the pseudo id of the incoming edge to the initial nodes
+const initialId = -1
+
+// tableau is a node under construction in the algorithm of
+// Gerth, Peled, Vardi and Wolper, "Simple On-the-fly Automatic Verification
+// of Linear Temporal Logic" (1995).
+type tableau struct {
+	incoming map[int]bool
+	new      map[string]*formula
+	old      map[string]*formula
+	next     map[string]*formula
+}
+
+// Translate constructs a Büchi automaton which accepts
+// exactly the infinite sequences satisfying the formula.
+func Translate(f Formula) Automaton {
+
+	phi := unwrap(f).normalize(false)
+	done := []*tableau{}
+	expand(&tableau{
+		incoming: map[int]bool{initialId: true},
+		new:      map[string]*formula{phi.String(): phi},
+		old:      map[string]*formula{},
+		next:     map[string]*formula{},
+	}, &done)
+
+	nodes := make([]node, len(done))
+	for id, t := range done {
+		nodes[id] = node{
+			id:         id,
+			initial:    t.incoming[initialId],
+			positive:   []string{},
+			negative:   []string{},
+			successors: []int{},
+		}
+		for _, g := range t.old {
+			if g.op == opProp {
+				nodes[id].positive = append(nodes[id].positive, g.name)
+			}
+			if g.op == opNot {
+				nodes[id].negative = append(nodes[id].negative, g.left.name)
+			}
+		}
+		sort.Strings(nodes[id].positive)
+		sort.Strings(nodes[id].negative)
+	}
+	for id, t := range done {
+		for from := range t.incoming {
+			if from != initialId {
+				nodes[from].successors = append(nodes[from].successors, id)
+			}
+		}
+	}
+
+	untils := map[string]*formula{}
+	phi.untils(untils)
+	keys := []string{}
+	for k := range untils {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	acceptance := [][]int{}
+	for _, k := range keys {
+		set := []int{}
+		for id, t := range done {
+			_, pending := t.old[k]
+			_, fulfilled := t.old[untils[k].right.String()]
+			if !pending || fulfilled {
+				set = append(set, id)
+			}
+		}
+		acceptance = append(acceptance, set)
+	}
+
+	a := automaton{nodes: make([]Node, len(nodes)), acceptance: acceptance}
+	for id, n := range nodes {
+		sort.Ints(n.successors)
+		a.nodes[id] = n
+	}
+	return a
+
+}
+
+func expand(t *tableau, done *[]*tableau) {
+
+	if len(t.new) == 0 {
+		for _, d := range *done {
+			if sameKeys(d.old, t.old) && sameKeys(d.next, t.next) {
+				for from := range t.incoming {
+					d.incoming[from] = true
+				}
+				return
+			}
+		}
+		id := len(*done)
+		*done = append(*done, t)
+		expand(&tableau{
+			incoming: map[int]bool{id: true},
+			new:      clone(t.next),
+			old:      map[string]*formula{},
+			next:     map[string]*formula{},
+		}, done)
+		return
+	}
+
+	var key string
+	for k := range t.new {
+		if key == "" || k < key {
+			key = k
+		}
+	}
+	eta := t.new[key]
+	delete(t.new, key)
+	if _, ok := t.old[key]; ok {
+		expand(t, done)
+		return
+	}
+
+	switch eta.op {
+	case opTrue, opFalse, opProp, opNot:
+		if eta.op == opFalse || contradicts(eta, t.old) {
+			return
+		}
+		t.old[key] = eta
+		expand(t, done)
+	case opAnd:
+		t.old[key] = eta
+		t.add(eta.left)
+		t.add(eta.right)
+		expand(t, done)
+	case opNext:
+		t.old[key] = eta
+		t.next[eta.left.String()] = eta.left
+		expand(t, done)
+	case opOr, opUntil, opRelease:
+		u, v := t.split(), t.split()
+		u.old[key] = eta
+		v.old[key] = eta
+		switch eta.op {
+		case opOr:
+			u.add(eta.left)
+			v.add(eta.right)
+		case opUntil:
+			u.add(eta.left)
+			u.next[key] = eta
+			v.add(eta.right)
+		case opRelease:
+			u.add(eta.right)
+			u.next[key] = eta
+			v.add(eta.left)
+			v.add(eta.right)
+		}
+		expand(u, done)
+		expand(v, done)
+	}
+
+}
+
+func (t *tableau) add(f *formula) {
+	if _, ok := t.old[f.String()]; !ok {
+		t.new[f.String()] = f
+	}
+}
+
+func (t *tableau) split() *tableau {
+	incoming := map[int]bool{}
+	for from := range t.incoming {
+		incoming[from] = true
+	}
+	return &tableau{
+		incoming: incoming,
+		new:      clone(t.new),
+		old:      clone(t.old),
+		next:     clone(t.next),
+	}
+}
+
+// contradicts tells whether the negation of the literal is already assumed.
+func contradicts(lit *formula, old map[string]*formula) bool {
+	switch lit.op {
+	case opProp:
+		_, ok := old[(&formula{op: opNot, left: lit}).String()]
+		return ok
+	case opNot:
+		_, ok := old[lit.left.String()]
+		return ok
+	}
+	return false
+}
+
+func (f *formula) untils(found map[string]*formula) {
+	if f.op == opUntil {
+		found[f.String()] = f
+	}
+	if f.left != nil {
+		f.left.untils(found)
+	}
+	if f.right != nil {
+		f.right.untils(found)
+	}
+}
+
+func clone(fs map[string]*formula) map[string]*formula {
+	c := map[string]*formula{}
+	for k, f := range fs {
+		c[k] = f
+	}
+	return c
+}
+
+func sameKeys(xs, ys map[string]*formula) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for k := range xs {
+		if _, ok := ys[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,214 @@
+// Package ltl provides linear temporal logic formulas
+// and their translation into Büchi automata.
+package ltl
+
+import (
+	"fmt"
+	"sort"
+)
+
+type operator int
+
+const (
+	opTrue operator = iota
+	opFalse
+	opProp
+	opNot
+	opAnd
+	opOr
+	opImplies
+	opNext
+	opEventually
+	opAlways
+	opUntil
+	opRelease
+)
+
+// Formula is a formula of linear temporal logic
+// whose atomic propositions are referred by their names.
+type Formula interface {
+	String() string
+	node() *formula
+}
+
+type formula struct {
+	op    operator
+	name  string
+	left  *formula
+	right *formula
+}
+
+func True() Formula {
+	return &formula{op: opTrue}
+}
+
+func False() Formula {
+	return &formula{op: opFalse}
+}
+
+// Prop is an atomic proposition, which is interpreted by the model checker.
+func Prop(name string) Formula {
+	return &formula{op: opProp, name: name}
+}
+
+func Not(f Formula) Formula {
+	return &formula{op: opNot, left: unwrap(f)}
+}
+
+func And(f, g Formula) Formula {
+	return &formula{op: opAnd, left: unwrap(f), right: unwrap(g)}
+}
+
+func Or(f, g Formula) Formula {
+	return &formula{op: opOr, left: unwrap(f), right: unwrap(g)}
+}
+
+func Implies(f, g Formula) Formula {
+	return &formula{op: opImplies, left: unwrap(f), right: unwrap(g)}
+}
+
+// Next holds if f holds at the next moment.
+func Next(f Formula) Formula {
+	return &formula{op: opNext, left: unwrap(f)}
+}
+
+// Eventually holds if f holds at some moment in the future.
+func Eventually(f Formula) Formula {
+	return &formula{op: opEventually, left: unwrap(f)}
+}
+
+// Always holds if f holds at every moment in the future.
+func Always(f Formula) Formula {
+	return &formula{op: opAlways, left: unwrap(f)}
+}
+
+// Until holds if g holds at some moment and f holds until then.
+func Until(f, g Formula) Formula {
+	return &formula{op: opUntil, left: unwrap(f), right: unwrap(g)}
+}
+
+// Release holds if g holds until and including the moment f holds,
+// or g holds forever.
+func Release(f, g Formula) Formula {
+	return &formula{op: opRelease, left: unwrap(f), right: unwrap(g)}
+}
+
+// Propositions returns the names of atomic propositions in the formula.
+func Propositions(f Formula) []string {
+	found := map[string]bool{}
+	unwrap(f).propositions(found)
+	names := []string{}
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func unwrap(f Formula) *formula {
+	return f.node()
+}
+
+func (f *formula) node() *formula {
+	return f
+}
+
+func (f *formula) String() string {
+	switch f.op {
+	case opTrue:
+		return "true"
+	case opFalse:
+		return "false"
+	case opProp:
+		return f.name
+	case opNot:
+		return fmt.Sprintf("!%s", f.left)
+	case opAnd:
+		return fmt.Sprintf("(%s && %s)", f.left, f.right)
+	case opOr:
+		return fmt.Sprintf("(%s || %s)", f.left, f.right)
+	case opImplies:
+		return fmt.Sprintf("(%s -> %s)", f.left, f.right)
+	case opNext:
+		return fmt.Sprintf("X %s", f.left)
+	case opEventually:
+		return fmt.Sprintf("<> %s", f.left)
+	case opAlways:
+		return fmt.Sprintf("[] %s", f.left)
+	case opUntil:
+		return fmt.Sprintf("(%s U %s)", f.left, f.right)
+	case opRelease:
+		return fmt.Sprintf("(%s R %s)", f.left, f.right)
+	}
+	return "?"
+}
+
+func (f *formula) propositions(found map[string]bool) {
+	if f.op == opProp {
+		found[f.name] = true
+	}
+	if f.left != nil {
+		f.left.propositions(found)
+	}
+	if f.right != nil {
+		f.right.propositions(found)
+	}
+}
+
+// normalize translates the formula into the negation normal form,
+// which consists of literals, And, Or, Next, Until and Release.
+func (f *formula) normalize(negated bool) *formula {
+	switch f.op {
+	case opTrue, opFalse:
+		if negated == (f.op == opTrue) {
+			return &formula{op: opFalse}
+		}
+		return &formula{op: opTrue}
+	case opProp:
+		if negated {
+			return &formula{op: opNot, left: f}
+		}
+		return f
+	case opNot:
+		return f.left.normalize(!negated)
+	case opAnd, opOr:
+		op := f.op
+		if negated {
+			op = dual(op)
+		}
+		return &formula{op: op, left: f.left.normalize(negated), right: f.right.normalize(negated)}
+	case opImplies:
+		op := opOr
+		if negated {
+			op = opAnd
+		}
+		return &formula{op: op, left: f.left.normalize(!negated), right: f.right.normalize(negated)}
+	case opNext:
+		return &formula{op: opNext, left: f.left.normalize(negated)}
+	case opEventually:
+		return (&formula{op: opUntil, left: &formula{op: opTrue}, right: f.left}).normalize(negated)
+	case opAlways:
+		return (&formula{op: opRelease, left: &formula{op: opFalse}, right: f.left}).normalize(negated)
+	case opUntil, opRelease:
+		op := f.op
+		if negated {
+			op = dual(op)
+		}
+		return &formula{op: op, left: f.left.normalize(negated), right: f.right.normalize(negated)}
+	}
+	return f
+}
+
+func dual(op operator) operator {
+	switch op {
+	case opAnd:
+		return opOr
+	case opOr:
+		return opAnd
+	case opUntil:
+		return opRelease
+	case opRelease:
+		return opUntil
+	}
+	return op
+}
@@ -0,0 +1,76 @@
+package ltl_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/ltl"
+)
+
+func TestString(t *testing.T) {
+
+	tests := []struct {
+		name string
+		in   ltl.Formula
+		want string
+	}{
+		{"prop", ltl.Prop("p"), "p"},
+		{"not", ltl.Not(ltl.Prop("p")), "!p"},
+		{"response", ltl.Always(ltl.Implies(ltl.Prop("req"), ltl.Eventually(ltl.Prop("ack")))), "[] (req -> <> ack)"},
+		{"until", ltl.Until(ltl.True(), ltl.Next(ltl.False())), "(true U X false)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.String(); got != tt.want {
+				t.Fatalf("want %s, but %s", tt.want, got)
+			}
+		})
+	}
+
+}
+
+func TestPropositions(t *testing.T) {
+	in := ltl.Or(ltl.Prop("q"), ltl.Release(ltl.Prop("p"), ltl.Not(ltl.Prop("q"))))
+	want := []string{"p", "q"}
+	if got := ltl.Propositions(in); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, but %v", want, got)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		in         ltl.Formula
+		acceptance int
+	}{
+		{"literal", ltl.Prop("p"), 0},
+		{"eventually", ltl.Eventually(ltl.Prop("p")), 1},
+		{"always", ltl.Always(ltl.Prop("p")), 0},
+		{"response", ltl.Always(ltl.Implies(ltl.Prop("p"), ltl.Eventually(ltl.Prop("q")))), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := ltl.Translate(tt.in)
+			if len(a.Acceptance()) != tt.acceptance {
+				t.Fatalf("want %d acceptance sets, but %d", tt.acceptance, len(a.Acceptance()))
+			}
+			initial := false
+			for _, n := range a.Nodes() {
+				initial = initial || n.Initial()
+			}
+			if !initial {
+				t.Fatalf("want initial nodes, but has none")
+			}
+		})
+	}
+
+}
+
+func TestTranslateFalse(t *testing.T) {
+	if n := len(ltl.Translate(ltl.False()).Nodes()); n != 0 {
+		t.Fatalf("want no node, but %d", n)
+	}
+}
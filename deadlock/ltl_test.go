@@ -0,0 +1,294 @@
+package deadlock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/ltl"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+func TestCheckLTL(t *testing.T) {
+
+	toggle := deadlock.NewSystem().
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").MoveTo("1")).
+			Define(rule.At("1").MoveTo("0")))
+
+	halt := deadlock.NewSystem().
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").MoveTo("1")).
+			HaltAt("1"))
+
+	proc := deadlock.NewProcess().
+		EnterAt("idle").
+		Define(rule.At("idle").MoveTo("wait")).
+		Define(rule.At("wait").Only(when.Var("mut").Is(0)).
+			Let("lock", do.Set(1).ToVar("mut")).MoveTo("crit")).
+		Define(rule.At("crit").
+			Let("unlock", do.Set(0).ToVar("mut")).MoveTo("idle"))
+	mutex := deadlock.NewSystem().
+		Declare(vars.Shared{"mut": 0}).
+		Register("P", proc).
+		Register("Q", proc)
+
+	at := func(pid deadlock.ProcessId, l rule.Location) deadlock.Predicate {
+		return func(ls deadlock.LocationSet, _ vars.Shared) (bool, error) {
+			return ls[pid] == l, nil
+		}
+	}
+	props := map[string]deadlock.Predicate{
+		"p0":    at("P", "0"),
+		"p1":    at("P", "1"),
+		"pwait": at("P", "wait"),
+		"pcrit": at("P", "crit"),
+		"qcrit": at("Q", "crit"),
+	}
+	p0, p1 := ltl.Prop("p0"), ltl.Prop("p1")
+
+	tests := []struct {
+		name      string
+		in        deadlock.System
+		formula   ltl.Formula
+		want      bool
+		wantError bool
+	}{
+		{"infinitely often", toggle, ltl.Always(ltl.Eventually(p1)), true, false},
+		{"eventually always", toggle, ltl.Eventually(ltl.Always(p1)), false, false},
+		{"always", toggle, ltl.Always(p0), false, false},
+		{"next", toggle, ltl.Next(p1), true, false},
+		{"until", toggle, ltl.Until(p0, p1), true, false},
+		{"release", toggle, ltl.Release(p1, p0), false, false},
+		{"stutter holds", halt, ltl.Eventually(ltl.Always(p1)), true, false},
+		{"stutter violates", halt, ltl.Always(ltl.Eventually(p0)), false, false},
+		{
+			"mutual exclusion", mutex,
+			ltl.Always(ltl.Not(ltl.And(ltl.Prop("pcrit"), ltl.Prop("qcrit")))),
+			true, false,
+		},
+		{
+			"starvation", mutex,
+			ltl.Always(ltl.Implies(ltl.Prop("pwait"), ltl.Eventually(ltl.Prop("pcrit")))),
+			false, false,
+		},
+		{"undefined", toggle, ltl.Prop("undefined"), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp, err := deadlock.NewDetector().Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			got, err := deadlock.CheckLTL(tt.in, rp, tt.formula, props)
+			if tt.wantError && err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+			if !tt.wantError && err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if tt.wantError {
+				return
+			}
+			if (got == nil) != tt.want {
+				t.Fatalf("want %v for %s, but has counterexample %+v", tt.want, tt.formula, got)
+			}
+			if got != nil && !connected(rp.Initial(), append(got.Stem(), got.Loop()...)) {
+				t.Fatalf("want connected lasso, but %+v", got)
+			}
+		})
+	}
+
+}
+
+func TestCheckLTLIncomplete(t *testing.T) {
+
+	in := deadlock.NewSystem().
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").MoveTo("1")))
+
+	tests := []struct {
+		name string
+		opts deadlock.Options
+	}{
+		{"state limit", deadlock.Options{MaxStates: 1}},
+		{"partial order", deadlock.Options{PartialOrder: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp, err := deadlock.NewDetector().DetectContext(context.Background(), in, tt.opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if _, err := deadlock.CheckLTL(in, rp, ltl.True(), nil); err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+		})
+	}
+
+}
+
+func TestCheckLTLSymmetry(t *testing.T) {
+
+	stepper := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").MoveTo("1")).
+		HaltAt("1")
+	replicas := func(p deadlock.Process) deadlock.System {
+		return deadlock.NewSystem().
+			RegisterSymmetric(
+				deadlock.NewReplica("P", p),
+				deadlock.NewReplica("Q", p))
+	}
+
+	props := map[string]deadlock.Predicate{
+		"qdone": func(ls deadlock.LocationSet, _ vars.Shared) (bool, error) {
+			return ls["Q"] == "1", nil
+		},
+		"alldone": func(ls deadlock.LocationSet, _ vars.Shared) (bool, error) {
+			return ls["P"] == "1" && ls["Q"] == "1", nil
+		},
+	}
+
+	tests := []struct {
+		name      string
+		in        deadlock.System
+		formula   ltl.Formula
+		wantError bool
+	}{
+		{"symmetric proposition", replicas(stepper), ltl.Always(ltl.Prop("alldone")), false},
+		{"process-named proposition", replicas(stepper), ltl.Eventually(ltl.Prop("qdone")), true},
+		{"fair process", replicas(stepper.Fair(rule.Weak)), ltl.Always(ltl.Prop("alldone")), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp, err := deadlock.NewDetector().Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			got, err := deadlock.CheckLTL(tt.in, rp, tt.formula, props)
+			if tt.wantError && err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+			if !tt.wantError && err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !tt.wantError && got == nil {
+				t.Fatalf("want counterexample, but has none")
+			}
+		})
+	}
+
+}
+
+func TestCheckLTLFairness(t *testing.T) {
+
+	toggler := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").MoveTo("1")).
+		Define(rule.At("1").MoveTo("0"))
+	stepper := deadlock.NewProcess().
+		EnterAt("0").
+		Define(rule.At("0").MoveTo("1")).
+		HaltAt("1")
+	lock := func(f rule.Fairness) rule.Rule {
+		return rule.At("wait").Only(when.Var("mut").Is(0)).
+			Let("lock", do.Set(1).ToVar("mut")).MoveTo("crit").Fair(f)
+	}
+	proc := func(f rule.Fairness) deadlock.Process {
+		return deadlock.NewProcess().
+			EnterAt("idle").
+			Define(rule.At("idle").MoveTo("wait")).
+			Define(lock(f)).
+			Define(rule.At("crit").
+				Let("unlock", do.Set(0).ToVar("mut")).MoveTo("idle"))
+	}
+
+	props := map[string]deadlock.Predicate{
+		"qdone": func(ls deadlock.LocationSet, _ vars.Shared) (bool, error) {
+			return ls["Q"] == "1", nil
+		},
+		"pwait": func(ls deadlock.LocationSet, _ vars.Shared) (bool, error) {
+			return ls["P"] == "wait", nil
+		},
+		"pcrit": func(ls deadlock.LocationSet, _ vars.Shared) (bool, error) {
+			return ls["P"] == "crit", nil
+		},
+	}
+	eventually := ltl.Eventually(ltl.Prop("qdone"))
+	response := ltl.Always(ltl.Implies(ltl.Prop("pwait"), ltl.Eventually(ltl.Prop("pcrit"))))
+
+	tests := []struct {
+		name    string
+		in      deadlock.System
+		formula ltl.Formula
+		want    bool
+	}{
+		{
+			"unfair process",
+			deadlock.NewSystem().
+				Register("P", toggler).
+				Register("Q", stepper),
+			eventually, false,
+		},
+		{
+			"weakly fair process",
+			deadlock.NewSystem().
+				Register("P", toggler).
+				Register("Q", stepper.Fair(rule.Weak)),
+			eventually, true,
+		},
+		{
+			"weakly fair rule",
+			deadlock.NewSystem().
+				Register("P", toggler).
+				Register("Q", deadlock.NewProcess().
+					EnterAt("0").
+					Define(rule.At("0").MoveTo("1").Fair(rule.Weak)).
+					HaltAt("1")),
+			eventually, true,
+		},
+		{
+			"weakly fair lock",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"mut": 0}).
+				Register("P", proc(rule.Weak).Fair(rule.Weak)).
+				Register("Q", proc(rule.Weak).Fair(rule.Weak)),
+			response, false,
+		},
+		{
+			"strongly fair lock",
+			deadlock.NewSystem().
+				Declare(vars.Shared{"mut": 0}).
+				Register("P", proc(rule.Strong)).
+				Register("Q", proc(rule.Strong)),
+			response, true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rp, err := deadlock.NewDetector().Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			got, err := deadlock.CheckLTL(tt.in, rp, tt.formula, props)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if (got == nil) != tt.want {
+				t.Fatalf("want %v for %s, but has counterexample %+v", tt.want, tt.formula, got)
+			}
+		})
+	}
+
+}
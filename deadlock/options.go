@@ -0,0 +1,237 @@
+package deadlock
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Options bounds the state space search.
+// The zero value imposes no limit, i.e. the search continues
+// until every reachable state is visited.
+type Options struct {
+	// MaxStates is the maximum number of states to be visited.
+	MaxStates int
+	// MaxDepth is the maximum length of transition sequences
+	// from the initial state. States at the depth are not expanded.
+	MaxDepth int
+	// Timeout is the wall-clock budget of the search.
+	Timeout time.Duration
+	// Livelock enables the detection of reachable cycles
+	// in which no transition is marked as progress.
+	Livelock bool
+	// PartialOrder enables the partial order reduction, which skips
+	// redundant interleavings of independent transitions. It preserves
+	// the reachability of deadlocks, but not the other properties,
+	// so that it is rejected together with Livelock or invariants of the system,
+	// and the report cannot be checked by CheckLTL.
+	// Only rules with known footprints are regarded as independent.
+	PartialOrder bool
+	// Store keeps the visited states and transitions.
+	// If nil, they are kept in memory by NewMemoryStore.
+	Store StateStore
+	// Bitstate, if positive, is the size in bits of the table
+	// which replaces the visited states. Each visited state sets
+	// a few bits of it, so that distinct states may be mistaken
+	// for each other. The report contains only the states and
+	// transitions on the traces, and estimates the coverage.
+	// Livelocks cannot be searched in this mode.
+	Bitstate int
+	// HashCompaction replaces the visited states with their 64-bit
+	// fingerprints, which distinct states share with a small probability.
+	// As in bitstate hashing, the report contains only the traces.
+	HashCompaction bool
+	// Collapse shares the equal location sets and variables
+	// among the states, which saves memory in exhaustive searches
+	// where many states differ only in a few components.
+	Collapse bool
+	// Strategy is the order in which states are visited.
+	Strategy Strategy
+	// StopAtFirstDeadlock stops the search as soon as a deadlock is found.
+	// The parallel detector may have visited more states at the same depth.
+	StopAtFirstDeadlock bool
+	// Heuristic guides the BestFirst and AStar strategies.
+	// If nil, they use EnabledProcesses of the system.
+	Heuristic Heuristic
+	// Checkpoint is the file where the sequential detector periodically
+	// saves the progress of the search, which Resume continues.
+	// It is also saved when the search is canceled or timed out.
+	// Only the exhaustive breadth-first and depth-first searches
+	// with the default store can be checkpointed.
+	Checkpoint string
+	// CheckpointInterval is the wall-clock time between checkpoints,
+	// one minute by default.
+	CheckpointInterval time.Duration
+	// Observer is notified of the statistics of the search.
+	Observer Observer
+	// ObserveInterval is the wall-clock time between notifications,
+	// one second by default.
+	ObserveInterval time.Duration
+}
+
+// Strategy is the order of the state space search.
+type Strategy int
+
+const (
+	// BreadthFirst visits states in the order of their distance
+	// from the initial state, which finds the shortest traces.
+	BreadthFirst Strategy = iota
+	// DepthFirst follows each path as far as possible before backtracking.
+	// It keeps fewer states waiting, but the traces may be longer.
+	DepthFirst
+	// IterativeDeepening repeats depth-first searches bounded by
+	// increasing depths, up to Options.MaxDepth if it is set.
+	// It stops deepening once the states at the bound lead only
+	// to visited states. The report is the one of the last search.
+	IterativeDeepening
+	// BestFirst visits the states of the smallest Options.Heuristic first.
+	// It stops at the first deadlock.
+	BestFirst
+	// AStar visits the states of the smallest sum of the distance
+	// from the initial state and Options.Heuristic first.
+	// It stops at the first deadlock.
+	AStar
+)
+
+func (st Strategy) String() string {
+	switch st {
+	case BreadthFirst:
+		return "breadth-first"
+	case DepthFirst:
+		return "depth-first"
+	case IterativeDeepening:
+		return "iterative deepening"
+	case BestFirst:
+		return "best-first"
+	case AStar:
+		return "A*"
+	}
+	return "unknown"
+}
+
+// StopReason tells why the search stopped.
+type StopReason int
+
+const (
+	// Exhausted means that every reachable state has been visited.
+	Exhausted StopReason = iota
+	// Canceled means that the given context was canceled.
+	Canceled
+	// TimedOut means that the timeout or the context's deadline expired.
+	TimedOut
+	// StateLimitReached means that the number of states hit Options.MaxStates.
+	StateLimitReached
+	// DepthLimitReached means that some states at Options.MaxDepth
+	// had successors which were not explored.
+	DepthLimitReached
+	// DeadlockFound means that the search stopped at the first deadlock
+	// as Options.StopAtFirstDeadlock requests.
+	DeadlockFound
+	// Simulated means that the states were sampled by random walks.
+	Simulated
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case Exhausted:
+		return "exhausted"
+	case Canceled:
+		return "canceled"
+	case TimedOut:
+		return "timed out"
+	case StateLimitReached:
+		return "state limit reached"
+	case DepthLimitReached:
+		return "depth limit reached"
+	case DeadlockFound:
+		return "deadlock found"
+	case Simulated:
+		return "simulated"
+	}
+	return "unknown"
+}
+
+func (o Options) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.Timeout > 0 {
+		return context.WithTimeout(ctx, o.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (o Options) store() (StateStore, error) {
+	if o.lossy() {
+		if o.Bitstate > 0 && o.HashCompaction {
+			return nil, fmt.Errorf("bitstate search and hash compaction are exclusive")
+		}
+		if o.Store != nil {
+			return nil, fmt.Errorf("lossy search cannot use the state store")
+		}
+		if o.Livelock {
+			return nil, fmt.Errorf("lossy search cannot find livelocks")
+		}
+		if o.Bitstate > 0 {
+			return newBitstateStore(o.Bitstate), nil
+		}
+		return newCompactStore(), nil
+	}
+	if o.Store == nil {
+		return NewMemoryStore(), nil
+	}
+	if o.Store.CountStates() > 0 {
+		return nil, fmt.Errorf("state store is not empty")
+	}
+	return o.Store, nil
+}
+
+// lossy tells whether the search does not keep every visited state.
+func (o Options) lossy() bool {
+	return o.Bitstate > 0 || o.HashCompaction
+}
+
+const defaultCheckpointInterval = time.Minute
+
+func (o Options) checkpointInterval() time.Duration {
+	if o.CheckpointInterval <= 0 {
+		return defaultCheckpointInterval
+	}
+	return o.CheckpointInterval
+}
+
+// checkpointable tells why the search cannot be checkpointed, if so.
+func (o Options) checkpointable() error {
+	switch {
+	case o.Store != nil:
+		return fmt.Errorf("checkpoint cannot save the state store")
+	case o.lossy():
+		return fmt.Errorf("checkpoint cannot save lossy search")
+	case o.Strategy != BreadthFirst && o.Strategy != DepthFirst:
+		return fmt.Errorf("checkpoint cannot save %s search", o.Strategy)
+	}
+	return nil
+}
+
+// directed tells whether the strategy is guided by the heuristic.
+func (o Options) directed() bool {
+	return o.Strategy == BestFirst || o.Strategy == AStar
+}
+
+func (o Options) allowsStates(n int) bool {
+	return o.MaxStates <= 0 || n < o.MaxStates
+}
+
+func (o Options) allowsDepth(d int) bool {
+	return o.MaxDepth <= 0 || d < o.MaxDepth
+}
+
+// interrupted checks the context without blocking.
+func interrupted(ctx context.Context) (StopReason, bool) {
+	select {
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return TimedOut, true
+		}
+		return Canceled, true
+	default:
+		return Exhausted, false
+	}
+}
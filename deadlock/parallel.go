@@ -0,0 +1,279 @@
+package deadlock
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// NewParallelDetector returns a detector which expands each BFS frontier
+// by the given number of goroutines. If workers is not positive,
+// it uses as many goroutines as runtime.GOMAXPROCS allows.
+// The resulting report is identical to the one of NewDetector.
+func NewParallelDetector(workers int) Detector {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return parallelDetector{workers: workers}
+}
+
+type parallelDetector struct {
+	workers int
+}
+
+// expansion is the result of firing rules at a state in the frontier.
+type expansion struct {
+	broken []string
+	steps  []step
+	err    error
+}
+
+func (d parallelDetector) Detect(s System) (Report, error) {
+	return d.DetectContext(context.Background(), s, Options{})
+}
+
+func (d parallelDetector) DetectContext(ctx context.Context, s System, opts Options) (Report, error) {
+
+	ctx, cancel := opts.context(ctx)
+	defer cancel()
+
+	accepting := StateSet{}
+	deadlocked := StateSet{}
+	violated := map[StateId][]string{}
+	reason := Exhausted
+
+	if opts.lossy() {
+		return report{}, fmt.Errorf("parallel detector does not support lossy search")
+	}
+	if opts.Checkpoint != "" {
+		return report{}, fmt.Errorf("parallel detector does not support checkpoints")
+	}
+	if opts.Strategy != BreadthFirst {
+		return report{}, fmt.Errorf("parallel detector does not support %s search", opts.Strategy)
+	}
+	store, err := opts.store()
+	if err != nil {
+		return report{}, err
+	}
+	ex, err := newExpander(s, opts)
+	if err != nil {
+		return report{}, err
+	}
+	visited := newShardedStates(d.workers*4, store)
+	pg := newProgress()
+	initial := ex.initial()
+	if err := visited.offer(initial, discovery{}); err != nil {
+		return report{}, err
+	}
+	frontier, _, err := visited.settle(opts)
+	if err != nil {
+		return report{}, err
+	}
+
+	for depth := 0; len(frontier) > 0; depth++ {
+		pg.observe(opts, store, ex.collapser, len(frontier))
+
+		deeper := opts.allowsDepth(depth)
+		results := make([]expansion, len(frontier))
+		indices := make(chan int)
+		wg := sync.WaitGroup{}
+		for w := 0; w < d.workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range indices {
+					if ctx.Err() != nil {
+						continue
+					}
+					broken, err := violations(s, frontier[i])
+					if err != nil {
+						results[i] = expansion{err: err}
+						continue
+					}
+					steps, err := ex.successors(frontier[i])
+					results[i] = expansion{broken: broken, steps: steps, err: err}
+					if !deeper {
+						continue
+					}
+					for j, st := range steps {
+						if err := visited.offer(st.state, discovery{source: i, rank: j}); err != nil {
+							results[i].err = err
+							break
+						}
+					}
+				}
+			}()
+		}
+		for i := range frontier {
+			indices <- i
+		}
+		close(indices)
+		wg.Wait()
+
+		if r, ok := interrupted(ctx); ok {
+			reason = r
+			break
+		}
+
+		// merge in the frontier order, so that the first error
+		// is the same as the one which the sequential search encounters
+		found := false
+		for i, r := range results {
+			if r.err != nil {
+				return report{}, r.err
+			}
+			if len(r.broken) > 0 {
+				violated[frontier[i].Id()] = r.broken
+			}
+			if len(r.steps) > 0 && !deeper {
+				reason = DepthLimitReached
+				continue
+			}
+			pg.expanded(depth, len(r.steps))
+			for _, st := range r.steps {
+				if err := store.PutTransition(st.transition); err != nil {
+					return report{}, err
+				}
+			}
+			if len(r.steps) == 0 {
+				from := frontier[i]
+				if acceptable(s, from) {
+					accepting[from.Id()] = from
+					continue
+				}
+				deadlocked[from.Id()] = from
+				if opts.StopAtFirstDeadlock {
+					reason = DeadlockFound
+					found = true
+					break
+				}
+			}
+		}
+		if found {
+			break
+		}
+
+		var full bool
+		frontier, full, err = visited.settle(opts)
+		if err != nil {
+			return report{}, err
+		}
+		if full {
+			reason = StateLimitReached
+		}
+	}
+
+	sr := &search{
+		store:      store,
+		initial:    initial.Id(),
+		accepting:  accepting,
+		deadlocked: deadlocked,
+		violated:   violated,
+		reason:     reason,
+		progress:   pg,
+	}
+	return finish(s, opts, ex, sr, len(frontier))
+
+}
+
+// discovery orders the candidates of the next frontier
+// in the same way as the sequential BFS queue does.
+type discovery struct {
+	source int
+	rank   int
+}
+
+func (x discovery) before(y discovery) bool {
+	if x.source != y.source {
+		return x.source < y.source
+	}
+	return x.rank < y.rank
+}
+
+type candidate struct {
+	state State
+	at    discovery
+}
+
+type stateShard struct {
+	mu      sync.Mutex
+	pending map[StateId]candidate
+}
+
+// shardedStates holds the candidates of the next frontier
+// in independently locked shards. States offered during a level
+// are kept pending until settle, where the earliest discovery
+// of each state wins and it is moved into the store.
+type shardedStates struct {
+	shards []*stateShard
+	store  StateStore
+}
+
+func newShardedStates(n int, store StateStore) shardedStates {
+	shards := make([]*stateShard, n)
+	for i := range shards {
+		shards[i] = &stateShard{
+			pending: map[StateId]candidate{},
+		}
+	}
+	return shardedStates{shards: shards, store: store}
+}
+
+func (ss shardedStates) shard(id StateId) *stateShard {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return ss.shards[h.Sum32()%uint32(len(ss.shards))]
+}
+
+// offer returns an error if the state collides with a stored or pending one.
+func (ss shardedStates) offer(s State, at discovery) error {
+	// the store is not modified until settle
+	seen, err := lookupState(ss.store, s)
+	if err != nil || seen {
+		return err
+	}
+	sh := ss.shard(s.Id())
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	c, ok := sh.pending[s.Id()]
+	if ok && !sameState(c.state, s) {
+		return collisionError(s.Id())
+	}
+	if ok && !at.before(c.at) {
+		return nil
+	}
+	sh.pending[s.Id()] = candidate{state: s, at: at}
+	return nil
+}
+
+// settle stores the pending states
+// and returns them in the order of their discovery.
+// If the states exceed opts.MaxStates, the latest ones are discarded
+// and it reports that the visited set is full.
+func (ss shardedStates) settle(opts Options) ([]State, bool, error) {
+	n := ss.store.CountStates()
+	cs := []candidate{}
+	for _, sh := range ss.shards {
+		for _, c := range sh.pending {
+			cs = append(cs, c)
+		}
+		sh.pending = map[StateId]candidate{}
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].at.before(cs[j].at) })
+	full := false
+	if opts.MaxStates > 0 && n+len(cs) > opts.MaxStates {
+		cs = cs[:opts.MaxStates-n]
+		full = true
+	}
+	states := make([]State, len(cs))
+	for i, c := range cs {
+		if _, err := ss.store.PutState(c.state); err != nil {
+			return nil, false, err
+		}
+		states[i] = c.state
+	}
+	return states, full, nil
+}
@@ -0,0 +1,83 @@
+package deadlock
+
+import (
+	"time"
+)
+
+// Statistics summarizes the progress of a search.
+type Statistics struct {
+	// States is the number of visited states.
+	States int
+	// Transitions is the number of fired transitions, which may count
+	// a transition twice if its source state is expanded again.
+	Transitions int
+	// Frontier is the number of states waiting for the expansion.
+	Frontier int
+	// MaxDepth is the maximum depth of the expanded states.
+	MaxDepth int
+	// Elapsed is the wall-clock time since the search started or resumed.
+	Elapsed time.Duration
+	// StatesPerSecond is the average rate of visiting states.
+	StatesPerSecond float64
+	// Memory estimates the space which the visited states occupy.
+	Memory Memory
+}
+
+// Observer is notified of the statistics periodically during the search,
+// and once at the end of it. It is called from the goroutine of the search,
+// so it should return quickly.
+type Observer func(Statistics)
+
+const defaultObserveInterval = time.Second
+
+// progress counts what the stores do not know.
+type progress struct {
+	started     time.Time
+	notified    time.Time
+	transitions int
+	maxDepth    int
+}
+
+func newProgress() *progress {
+	now := time.Now()
+	return &progress{started: now, notified: now}
+}
+
+func (pg *progress) expanded(depth int, steps int) {
+	pg.transitions += steps
+	if depth > pg.maxDepth {
+		pg.maxDepth = depth
+	}
+}
+
+func (pg *progress) statistics(store StateStore, col *collapser, frontier int) Statistics {
+	elapsed := time.Since(pg.started)
+	stats := Statistics{
+		States:      store.CountStates(),
+		Transitions: pg.transitions,
+		Frontier:    frontier,
+		MaxDepth:    pg.maxDepth,
+		Elapsed:     elapsed,
+		Memory:      measure(store, col),
+	}
+	if elapsed > 0 {
+		stats.StatesPerSecond = float64(stats.States) / elapsed.Seconds()
+	}
+	return stats
+}
+
+// observe notifies the observer if the interval has passed since the last time.
+func (pg *progress) observe(opts Options, store StateStore, col *collapser, frontier int) {
+	if opts.Observer == nil {
+		return
+	}
+	interval := opts.ObserveInterval
+	if interval <= 0 {
+		interval = defaultObserveInterval
+	}
+	if time.Since(pg.notified) < interval {
+		return
+	}
+	opts.Observer(pg.statistics(store, col, frontier))
+	pg.notified = time.Now()
+}
@@ -0,0 +1,78 @@
+package deadlock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+func TestObserver(t *testing.T) {
+
+	counter := func(x vars.Name) deadlock.Process {
+		return deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var(x).IsLessThan(10)).
+				Let("incr", do.Add(1).ToVar(x)).MoveTo("0"))
+	}
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0, "y": 0}).
+		Register("P", counter("x")).
+		Register("Q", counter("y"))
+
+	detectors := []struct {
+		name     string
+		detector deadlock.Detector
+	}{
+		{"sequential", deadlock.NewDetector()},
+		{"parallel", deadlock.NewParallelDetector(2)},
+	}
+
+	for _, tt := range detectors {
+		t.Run(tt.name, func(t *testing.T) {
+			observed := []deadlock.Statistics{}
+			opts := deadlock.Options{
+				Observer: func(stats deadlock.Statistics) {
+					observed = append(observed, stats)
+				},
+				ObserveInterval: time.Nanosecond,
+			}
+			rp, err := tt.detector.DetectContext(context.Background(), in, opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+
+			if len(observed) < 2 {
+				t.Fatalf("want periodic notifications, but %d", len(observed))
+			}
+			for i := 1; i < len(observed); i++ {
+				if observed[i].States < observed[i-1].States {
+					t.Fatalf("want non-decreasing states, but %+v", observed)
+				}
+			}
+			got := rp.Statistics()
+			if observed[len(observed)-1] != got {
+				t.Fatalf("want the last notification %+v, but %+v", got, observed[len(observed)-1])
+			}
+			want := deadlock.Statistics{
+				States:      len(rp.Visited()),
+				Transitions: len(rp.Transited()),
+				Frontier:    0,
+				MaxDepth:    20,
+			}
+			if got.States != want.States || got.Transitions != want.Transitions ||
+				got.Frontier != want.Frontier || got.MaxDepth != want.MaxDepth {
+				t.Fatalf("want %+v, but %+v", want, got)
+			}
+			if got.Memory != rp.Memory() || got.Memory.VisitedBytes == 0 {
+				t.Fatalf("want memory estimate %+v, but %+v", rp.Memory(), got.Memory)
+			}
+		})
+	}
+
+}
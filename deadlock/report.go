@@ -1,8 +1,8 @@
 package deadlock
 
 import (
-	"crypto/sha1"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"sort"
 	"strings"
@@ -17,27 +17,32 @@ type StateSet map[StateId]State
 
 type LocationSet map[ProcessId]rule.Location
 
+// LocalVarSet holds the local variables of each process
+// which declares some of them.
+type LocalVarSet map[ProcessId]vars.Shared
+
 // State represents a state of the system's each moment
 // i.e. where processes are and what the value of variables are.
 type State interface {
 	Id() StateId
 	Locations() LocationSet
 	SharedVars() vars.Shared
+	LocalVars() LocalVarSet
 	Upstream() TransitionId
 }
 
 type state struct {
+	id         StateId
 	locations  LocationSet
 	sharedVars vars.Shared
+	localVars  LocalVarSet
 	upstream   TransitionId
 }
 
-// assume that s.Id() is independent from s.upstream
+// The id is assigned by the encoder of the search, see encoder.identify.
+// Note that s.Id() is independent from s.upstream.
 func (s state) Id() StateId {
-	h := sha1.New()
-	serial := fmt.Sprintf("%+v%+v", s.locations, s.sharedVars)
-	h.Write([]byte(serial))
-	return StateId(fmt.Sprintf("%x", h.Sum(nil)))
+	return s.id
 }
 
 func (s state) Locations() LocationSet {
@@ -48,6 +53,10 @@ func (s state) SharedVars() vars.Shared {
 	return s.sharedVars
 }
 
+func (s state) LocalVars() LocalVarSet {
+	return s.localVars
+}
+
 func (s state) Upstream() TransitionId {
 	return s.upstream
 }
@@ -63,20 +72,52 @@ type Transition interface {
 	Label() rule.Label
 	Source() StateId
 	Target() StateId
+	// Progress tells whether the transition is fired by a progress rule.
+	Progress() bool
+	// Processes returns every process which takes part in the transition,
+	// i.e. Process followed by the others in a joint transition
+	// over an unbuffered channel or a synchronization event.
+	Processes() []ProcessId
 }
 
 type transition struct {
+	id       TransitionId
+	process  ProcessId
+	label    rule.Label
+	source   StateId
+	target   StateId
+	progress bool
+	rule     ruleRef
+	// partners are the other processes of a joint transition,
+	// which is empty for the transitions of a single process.
+	partners []participant
+}
+
+// participant is another process which takes part in a joint transition.
+type participant struct {
 	process ProcessId
 	label   rule.Label
-	source  StateId
-	target  StateId
+	rule    ruleRef
+}
+
+// ruleRef identifies the rule fired by a transition
+// with its index in the rules of the process at the source location.
+type ruleRef struct {
+	source rule.Location
+	index  int
 }
 
 func (t transition) Id() TransitionId {
-	h := sha1.New()
-	serial := fmt.Sprintf("%+v", t)
-	h.Write([]byte(serial))
-	return TransitionId(fmt.Sprintf("%x", h.Sum(nil)))
+	return t.id
+}
+
+// identify hashes the encoded fields once by 128-bit FNV-1a,
+// since transitions are immutable. The id depends only on the fields.
+func (t transition) identify() transition {
+	h := fnv.New128a()
+	h.Write(appendTransition(nil, t))
+	t.id = TransitionId(fmt.Sprintf("%x", h.Sum(nil)))
+	return t
 }
 
 func (t transition) Process() ProcessId {
@@ -95,31 +136,147 @@ func (t transition) Target() StateId {
 	return t.target
 }
 
+func (t transition) Progress() bool {
+	return t.progress
+}
+
+func (t transition) Processes() []ProcessId {
+	pids := []ProcessId{t.process}
+	for _, p := range t.partners {
+		pids = append(pids, p.process)
+	}
+	return pids
+}
+
 // Report contains the result of the state space searching
 type Report interface {
+	// Visited returns the visited states, which lacks some states
+	// if the store fails to read them. See LoadVisited.
 	Visited() StateSet
+	// Transited returns the fired transitions, which lacks some
+	// transitions if the store fails to read them. See LoadTransited.
 	Transited() TransitionSet
+	// LoadVisited returns the visited states with the error in reading the store.
+	LoadVisited() (StateSet, error)
+	// LoadTransited returns the fired transitions with the error in reading the store.
+	LoadTransited() (TransitionSet, error)
 	Initial() StateId
 	Accepting() StateSet
 	Deadlocked() StateSet
+	// Violated returns the states which violate some invariants of the system.
+	Violated() StateSet
+	// Violations returns the names of invariants which the state violates.
+	Violations(StateId) []string
 	Traces() TransitionSet
+	// TraceTo returns the path from the initial state to the state,
+	// which is one of the shortest paths in breadth-first searches.
+	// It is empty if the state is the initial one or not visited.
+	TraceTo(StateId) []Transition
+	// Livelocks returns the reachable cycles without progress transitions.
+	// They are searched only if Options.Livelock is set.
+	Livelocks() []Lasso
+	// Complete tells whether every reachable state has been visited.
+	Complete() bool
+	StopReason() StopReason
+	// Coverage estimates how many states are missed by hash collisions.
+	Coverage() Coverage
+	// Channels returns the capacities of the channels in the system.
+	Channels() map[vars.Name]int
+	// Reduced tells whether the partial order reduction skipped
+	// some interleavings, which preserves only deadlocks.
+	Reduced() bool
+	// Memory estimates the space which the visited states occupy.
+	Memory() Memory
+	// Statistics summarizes the search at its end.
+	Statistics() Statistics
 }
 
 type report struct {
-	visited    StateSet
-	transited  TransitionSet
+	store      StateStore
 	initial    StateId
 	accepting  StateSet
 	deadlocked StateSet
+	violated   map[StateId][]string
+	broken     StateSet
 	traces     TransitionSet
+	paths      map[StateId][]Transition
+	livelocks  []Lasso
+	stopReason StopReason
+	coverage   Coverage
+	reduced    bool
+	// channels are the capacities of the channels in the system
+	channels   map[vars.Name]int
+	memory     Memory
+	statistics Statistics
+}
+
+func newReport(
+	store StateStore, initial StateId,
+	accepting StateSet, deadlocked StateSet, violated map[StateId][]string,
+) (report, error) {
+	broken := StateSet{}
+	for id := range violated {
+		s, _, err := store.GetState(id)
+		if err != nil {
+			return report{}, err
+		}
+		broken[id] = s
+	}
+	erroneous := []State{}
+	for _, s := range deadlocked {
+		erroneous = append(erroneous, s)
+	}
+	for _, s := range broken {
+		erroneous = append(erroneous, s)
+	}
+	traces := TransitionSet{}
+	paths := map[StateId][]Transition{}
+	for _, s := range erroneous {
+		path, err := pathTo(store, s.Id())
+		if err != nil {
+			return report{}, err
+		}
+		paths[s.Id()] = path
+		for _, t := range path {
+			traces[t.Id()] = t
+		}
+	}
+	return report{
+		store:      store,
+		initial:    initial,
+		accepting:  accepting,
+		deadlocked: deadlocked,
+		violated:   violated,
+		broken:     broken,
+		traces:     traces,
+		paths:      paths,
+	}, nil
 }
 
 func (rp report) Visited() StateSet {
-	return rp.visited
+	ss, _ := rp.LoadVisited()
+	return ss
 }
 
 func (rp report) Transited() TransitionSet {
-	return rp.transited
+	ts, _ := rp.LoadTransited()
+	return ts
+}
+
+// LoadVisited loads the states from the store.
+func (rp report) LoadVisited() (StateSet, error) {
+	if rp.store == nil {
+		return nil, nil
+	}
+	return loadStates(rp.store)
+}
+
+// LoadTransited loads the transitions from the store.
+func (rp report) LoadTransited() (TransitionSet, error) {
+	if rp.store == nil {
+		return nil, nil
+	}
+	return loadTransitions(rp.store)
 }
 
 func (rp report) Initial() StateId {
@@ -134,13 +291,71 @@ func (rp report) Deadlocked() StateSet {
 	return rp.deadlocked
 }
 
+func (rp report) Violated() StateSet {
+	return rp.broken
+}
+
+func (rp report) Violations(id StateId) []string {
+	return rp.violated[id]
+}
+
 func (rp report) Traces() TransitionSet {
 	return rp.traces
 }
 
+// TraceTo returns the traces of the erroneous states computed in advance,
+// and follows the upstreams in the store for the other states.
+func (rp report) TraceTo(id StateId) []Transition {
+	if path, ok := rp.paths[id]; ok {
+		return path
+	}
+	if rp.store == nil {
+		return []Transition{}
+	}
+	path, err := pathTo(rp.store, id)
+	if err != nil {
+		return []Transition{}
+	}
+	return path
+}
+
+func (rp report) Livelocks() []Lasso {
+	return rp.livelocks
+}
+
+func (rp report) Complete() bool {
+	return rp.stopReason == Exhausted
+}
+
+func (rp report) StopReason() StopReason {
+	return rp.stopReason
+}
+
+func (rp report) Coverage() Coverage {
+	return rp.coverage
+}
+
+func (rp report) Reduced() bool {
+	return rp.reduced
+}
+
+func (rp report) Memory() Memory {
+	return rp.memory
+}
+
+func (rp report) Channels() map[vars.Name]int {
+	return rp.channels
+}
+
+func (rp report) Statistics() Statistics {
+	return rp.statistics
+}
+
 // Printer outputs reports in Graphviz's dot notation
 type Printer struct {
 	writer io.Writer
+	// channels are the capacities of the channels in the printed report
+	channels map[vars.Name]int
 }
 
 func NewPrinter(w io.Writer) Printer {
@@ -148,11 +363,20 @@ func NewPrinter(w io.Writer) Printer {
 }
 
 func (pr Printer) Print(rp Report) (int, error) {
+	pr.channels = rp.Channels()
+	visited, err := rp.LoadVisited()
+	if err != nil {
+		return 0, err
+	}
+	transited, err := rp.LoadTransited()
+	if err != nil {
+		return 0, err
+	}
 	written, err := fmt.Fprintln(pr.writer, "digraph {")
 	if err != nil {
 		return written, err
 	}
-	for _, s := range rp.Visited() {
+	for _, s := range visited {
 		n := 0
 		if s.Id() == rp.Initial() {
 			n, err = pr.printInitial(s)
@@ -160,6 +384,8 @@ func (pr Printer) Print(rp Report) (int, error) {
 			n, err = pr.printAccepting(s)
 		} else if _, ok := rp.Deadlocked()[s.Id()]; ok {
 			n, err = pr.printDeadlocked(s)
+		} else if _, ok := rp.Violated()[s.Id()]; ok {
+			n, err = pr.printViolated(s, rp.Violations(s.Id()))
 		} else {
 			n, err = pr.printState(s)
 		}
@@ -168,10 +394,18 @@ func (pr Printer) Print(rp Report) (int, error) {
 			return written, err
 		}
 	}
-	for _, t := range rp.Transited() {
+	loops := TransitionSet{}
+	for _, l := range rp.Livelocks() {
+		for _, t := range l.Loop() {
+			loops[t.Id()] = t
+		}
+	}
+	for _, t := range transited {
 		n := 0
 		if _, ok := rp.Traces()[t.Id()]; ok {
 			n, err = pr.printTrace(t)
+		} else if _, ok := loops[t.Id()]; ok {
+			n, err = pr.printLoop(t)
 		} else {
 			n, err = pr.printTransition(t)
 		}
@@ -193,7 +427,7 @@ func (pr Printer) printState(s State) (int, error) {
 	return fmt.Fprintf(
 		pr.writer,
 		"  \"%s\" [label=\"%s\"]\n",
-		s.Id(), stateLabel(s),
+		s.Id(), stateLabel(s, pr.channels),
 	)
 }
 
@@ -201,7 +435,7 @@ func (pr Printer) printInitial(s State) (int, error) {
 	return fmt.Fprintf(
 		pr.writer,
 		"  \"%s\" [label=\"%s\", fillcolor=\"#AAFFFF\", style=\"solid,filled\"];\n",
-		s.Id(), stateLabel(s),
+		s.Id(), stateLabel(s, pr.channels),
 	)
 }
 
@@ -209,7 +443,7 @@ func (pr Printer) printAccepting(s State) (int, error) {
 	return fmt.Fprintf(
 		pr.writer,
 		"  \"%s\" [label=\"%s\", peripheries=2];\n",
-		s.Id(), stateLabel(s),
+		s.Id(), stateLabel(s, pr.channels),
 	)
 }
 
@@ -217,34 +451,89 @@ func (pr Printer) printDeadlocked(s State) (int, error) {
 	return fmt.Fprintf(
 		pr.writer,
 		"  \"%s\" [label=\"%s\", fillcolor=\"#FFAAAA\", style=\"solid,filled\"];\n",
-		s.Id(), stateLabel(s),
+		s.Id(), stateLabel(s, pr.channels),
+	)
+}
+
+func (pr Printer) printViolated(s State, names []string) (int, error) {
+	return fmt.Fprintf(
+		pr.writer,
+		"  \"%s\" [label=\"%s\\n!! %s\", fillcolor=\"#FFDDAA\", style=\"solid,filled\"];\n",
+		s.Id(), stateLabel(s, pr.channels), strings.Join(names, ", "),
 	)
 }
 
 func (pr Printer) printTransition(t Transition) (int, error) {
 	return fmt.Fprintf(
 		pr.writer,
-		"  \"%s\" -> \"%s\" [label=\"%s.%s\"];\n",
-		t.Source(), t.Target(), t.Process(), t.Label(),
+		"  \"%s\" -> \"%s\" [label=\"%s\"];\n",
+		t.Source(), t.Target(), transitionLabel(t),
 	)
 }
 
 func (pr Printer) printTrace(t Transition) (int, error) {
 	return fmt.Fprintf(
 		pr.writer,
-		"  \"%s\" -> \"%s\" [label=\"%s.%s\", color=\"#FF0000\", fontcolor=\"#FF0000\"];\n",
-		t.Source(), t.Target(), t.Process(), t.Label(),
+		"  \"%s\" -> \"%s\" [label=\"%s\", color=\"#FF0000\", fontcolor=\"#FF0000\"];\n",
+		t.Source(), t.Target(), transitionLabel(t),
+	)
+}
+
+func (pr Printer) printLoop(t Transition) (int, error) {
+	return fmt.Fprintf(
+		pr.writer,
+		"  \"%s\" -> \"%s\" [label=\"%s\", color=\"#0000FF\", fontcolor=\"#0000FF\"];\n",
+		t.Source(), t.Target(), transitionLabel(t),
 	)
 }
 
-func stateLabel(s State) string {
+func transitionLabel(t Transition) string {
+	label := fmt.Sprintf("%s.%s", t.Process(), t.Label())
+	if tr, ok := t.(transition); ok {
+		for _, p := range tr.partners {
+			label += fmt.Sprintf(" / %s.%s", p.process, p.label)
+		}
+	}
+	return label
+}
+
+// stateLabel shows the locations and the variables of the state,
+// where the variables of the buffered channels are shown as queues.
+func stateLabel(s State, channels map[vars.Name]int) string {
 	ss := []string{}
 	for pid, l := range s.Locations() {
-		ss = append(ss, fmt.Sprintf("%s @ %s", pid, l))
+		label := fmt.Sprintf("%s @ %s", pid, l)
+		if ls := s.LocalVars()[pid]; len(ls) > 0 {
+			ys := []string{}
+			for y, n := range ls {
+				ys = append(ys, fmt.Sprintf("%s = %d", y, n))
+			}
+			sort.Strings(ys)
+			label += fmt.Sprintf(" (%s)", strings.Join(ys, ", "))
+		}
+		ss = append(ss, label)
 	}
 	vs := []string{}
+	// the variables storing buffered channels are shown as queues
+	stored := map[vars.Name]bool{}
+	for ch, capacity := range channels {
+		xs := channelVars(ch, capacity)
+		if len(xs) == 0 {
+			continue
+		}
+		msgs := []string{}
+		for i := 0; i < s.SharedVars()[lengthVar(ch)] && i < capacity; i++ {
+			msgs = append(msgs, fmt.Sprintf("%d", s.SharedVars()[slotVar(ch, i)]))
+		}
+		for _, x := range xs {
+			stored[x] = true
+		}
+		vs = append(vs, fmt.Sprintf("%s = [%s]", ch, strings.Join(msgs, ", ")))
+	}
 	for x, n := range s.SharedVars() {
-		vs = append(vs, fmt.Sprintf("%s = %d", x, n))
+		if !stored[x] {
+			vs = append(vs, fmt.Sprintf("%s = %d", x, n))
+		}
 	}
 	sort.Strings(ss)
 	sort.Strings(vs)
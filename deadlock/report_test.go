@@ -0,0 +1,141 @@
+package deadlock_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+func TestTraceTo(t *testing.T) {
+
+	// P and Q race for x, and each of them gets stuck if it loses
+	racer := func(me int) deadlock.Process {
+		return deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Let("warm_up", do.Nothing()).MoveTo("1")).
+			Define(rule.At("1").Only(when.Var("x").Is(0)).
+				Let("win", do.Set(me).ToVar("x")).MoveTo("2"))
+	}
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0}).
+		Register("P", racer(1)).
+		Register("Q", racer(2)).
+		Register("R", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Only(when.Var("x").IsNot(0)).
+				Let("stop", do.Nothing()).MoveTo("1")))
+
+	rp, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	distances := distancesFrom(rp)
+
+	if len(rp.Deadlocked()) == 0 {
+		t.Fatalf("want deadlocks, but none")
+	}
+	traced := deadlock.TransitionSet{}
+	for id := range rp.Deadlocked() {
+		path := rp.TraceTo(id)
+		if !connected(rp.Initial(), path) || len(path) == 0 || path[len(path)-1].Target() != id {
+			t.Fatalf("want path to %s, but %v", id, path)
+		}
+		if len(path) != distances[id] {
+			t.Fatalf("want shortest path of %d steps to %s, but %d", distances[id], id, len(path))
+		}
+		for _, tr := range path {
+			traced[tr.Id()] = tr
+		}
+	}
+	if !eqTransitionIds(traced, rp.Traces()) {
+		t.Fatalf("want traces %v, but %v", rp.Traces(), traced)
+	}
+
+	for id := range rp.Visited() {
+		if len(rp.TraceTo(id)) != distances[id] {
+			t.Fatalf("want shortest path of %d steps to %s, but %v", distances[id], id, rp.TraceTo(id))
+		}
+	}
+	if len(rp.TraceTo("unknown")) != 0 {
+		t.Fatalf("want no path to unknown state, but %v", rp.TraceTo("unknown"))
+	}
+
+}
+
+// distancesFrom computes the distances of the states by an independent BFS.
+func distancesFrom(rp deadlock.Report) map[deadlock.StateId]int {
+	succ := map[deadlock.StateId][]deadlock.StateId{}
+	for _, t := range rp.Transited() {
+		succ[t.Source()] = append(succ[t.Source()], t.Target())
+	}
+	dist := map[deadlock.StateId]int{rp.Initial(): 0}
+	queue := []deadlock.StateId{rp.Initial()}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range succ[id] {
+			if _, ok := dist[next]; !ok {
+				dist[next] = dist[id] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return dist
+}
+
+// unreadableStore fails to read the states and transitions it holds.
+type unreadableStore struct {
+	deadlock.StateStore
+}
+
+func (us unreadableStore) EachState(f func(deadlock.State) error) error {
+	return fmt.Errorf("unreadable states")
+}
+
+func (us unreadableStore) EachTransition(f func(deadlock.Transition) error) error {
+	return fmt.Errorf("unreadable transitions")
+}
+
+func TestReportLoad(t *testing.T) {
+
+	in := philosophers(2, philosopher)
+
+	rp, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	states, transitions := len(rp.Visited()), len(rp.Transited())
+	for id := range rp.Visited() {
+		delete(rp.Visited(), id)
+	}
+	for id := range rp.Transited() {
+		delete(rp.Transited(), id)
+	}
+	if len(rp.Visited()) != states || len(rp.Transited()) != transitions {
+		t.Fatalf("want %d states and %d transitions kept, but %d and %d",
+			states, transitions, len(rp.Visited()), len(rp.Transited()))
+	}
+
+	opts := deadlock.Options{Store: unreadableStore{StateStore: deadlock.NewMemoryStore()}}
+	broken, err := deadlock.NewDetector().DetectContext(context.Background(), in, opts)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if _, err := broken.LoadVisited(); err == nil {
+		t.Fatalf("want error in loading states, but has no error")
+	}
+	if _, err := broken.LoadTransited(); err == nil {
+		t.Fatalf("want error in loading transitions, but has no error")
+	}
+	if _, err := deadlock.NewPrinter(ioutil.Discard).Print(broken); err == nil {
+		t.Fatalf("want error in printing, but has no error")
+	}
+
+}
@@ -3,18 +3,64 @@ package do
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
 )
 
 // Action changes the values of shared variables.
 // If the specified variable name is undeclared, it returns an error.
-type Action func(vars.Shared) (vars.Shared, error)
+//
+// The actions built by this package also implement vars.Described,
+// so that tools can tell which variables they read and write.
+type Action interface {
+	Apply(vars.Shared) (vars.Shared, error)
+}
 
-func Nothing() Action {
-	return func(vs vars.Shared) (vars.Shared, error) {
-		return vs.Clone(), nil
+// ActionFunc is an adapter to use a user-defined function as an Action.
+// Its footprint is unknown.
+type ActionFunc func(vars.Shared) (vars.Shared, error)
+
+func (f ActionFunc) Apply(vs vars.Shared) (vars.Shared, error) {
+	return f(vs)
+}
+
+// FootprintOf returns the variables which the action reads and writes.
+// It reports false if the action does not know its footprint.
+func FootprintOf(a Action) (vars.Footprint, bool) {
+	f, ok := a.(vars.Footprint)
+	return f, ok
+}
+
+// Describe returns the human-readable description of the action.
+func Describe(a Action) string {
+	if d, ok := a.(vars.Described); ok {
+		return d.Describe()
 	}
+	return "user-defined action"
+}
+
+func Nothing() Action {
+	return nothing{}
+}
+
+type nothing struct{}
+
+func (_ nothing) Apply(vs vars.Shared) (vars.Shared, error) {
+	return vs.Clone(), nil
+}
+
+func (_ nothing) Reads() []vars.Name {
+	return []vars.Name{}
+}
+
+func (_ nothing) Writes() []vars.Name {
+	return []vars.Name{}
+}
+
+func (_ nothing) Describe() string {
+	return "nothing"
 }
 
 type Operation interface {
@@ -30,17 +76,36 @@ func CopyVar(y vars.Name) Operation {
 }
 
 func (o copyVar) ToVar(x vars.Name) Action {
-	return func(vs vars.Shared) (vars.Shared, error) {
-		modified := vs.Clone()
-		if _, ok := vs[o.name]; !ok {
-			return vars.Shared{}, fmt.Errorf("undeclared variable: %s", o.name)
-		}
-		if _, ok := modified[x]; !ok {
-			return vars.Shared{}, fmt.Errorf("undeclared variable: %s", x)
-		}
-		modified[x] = vs[o.name]
-		return modified, nil
+	return copying{from: o.name, to: x}
+}
+
+type copying struct {
+	from vars.Name
+	to   vars.Name
+}
+
+func (a copying) Apply(vs vars.Shared) (vars.Shared, error) {
+	modified := vs.Clone()
+	if _, ok := vs[a.from]; !ok {
+		return vars.Shared{}, fmt.Errorf("undeclared variable: %s", a.from)
 	}
+	if _, ok := modified[a.to]; !ok {
+		return vars.Shared{}, fmt.Errorf("undeclared variable: %s", a.to)
+	}
+	modified[a.to] = vs[a.from]
+	return modified, nil
+}
+
+func (a copying) Reads() []vars.Name {
+	return []vars.Name{a.from}
+}
+
+func (a copying) Writes() []vars.Name {
+	return []vars.Name{a.to}
+}
+
+func (a copying) Describe() string {
+	return fmt.Sprintf("%s := %s", a.to, a.from)
 }
 
 type set struct {
@@ -52,14 +117,33 @@ func Set(n int) Operation {
 }
 
 func (o set) ToVar(x vars.Name) Action {
-	return func(vs vars.Shared) (vars.Shared, error) {
-		modified := vs.Clone()
-		if _, ok := modified[x]; !ok {
-			return vars.Shared{}, fmt.Errorf("undeclared variable: %s", x)
-		}
-		modified[x] = o.val
-		return modified, nil
+	return setting{val: o.val, to: x}
+}
+
+type setting struct {
+	val int
+	to  vars.Name
+}
+
+func (a setting) Apply(vs vars.Shared) (vars.Shared, error) {
+	modified := vs.Clone()
+	if _, ok := modified[a.to]; !ok {
+		return vars.Shared{}, fmt.Errorf("undeclared variable: %s", a.to)
 	}
+	modified[a.to] = a.val
+	return modified, nil
+}
+
+func (a setting) Reads() []vars.Name {
+	return []vars.Name{}
+}
+
+func (a setting) Writes() []vars.Name {
+	return []vars.Name{a.to}
+}
+
+func (a setting) Describe() string {
+	return fmt.Sprintf("%s := %d", a.to, a.val)
 }
 
 type add struct {
@@ -71,12 +155,280 @@ func Add(n int) Operation {
 }
 
 func (o add) ToVar(x vars.Name) Action {
-	return func(vs vars.Shared) (vars.Shared, error) {
-		modified := vs.Clone()
+	return adding{val: o.val, to: x}
+}
+
+type adding struct {
+	val int
+	to  vars.Name
+}
+
+func (a adding) Apply(vs vars.Shared) (vars.Shared, error) {
+	modified := vs.Clone()
+	if _, ok := modified[a.to]; !ok {
+		return vars.Shared{}, fmt.Errorf("undeclared variable: %s", a.to)
+	}
+	modified[a.to] = vs[a.to] + a.val
+	return modified, nil
+}
+
+func (a adding) Reads() []vars.Name {
+	return []vars.Name{a.to}
+}
+
+func (a adding) Writes() []vars.Name {
+	return []vars.Name{a.to}
+}
+
+func (a adding) Describe() string {
+	return fmt.Sprintf("%s += %d", a.to, a.val)
+}
+
+// Sub, Mul and Mod are the arithmetic operations with a constant,
+// and AddVar, SubVar, MulVar and ModVar are the ones
+// with the value of another variable.
+func Sub(n int) Operation {
+	return arithmetic{symbol: "-=", op: func(x, y int) (int, error) { return x - y, nil }, val: n}
+}
+
+func Mul(n int) Operation {
+	return arithmetic{symbol: "*=", op: func(x, y int) (int, error) { return x * y, nil }, val: n}
+}
+
+func Mod(n int) Operation {
+	return arithmetic{symbol: "%=", op: modulo, val: n}
+}
+
+func AddVar(y vars.Name) Operation {
+	return arithmetic{symbol: "+=", op: func(x, y int) (int, error) { return x + y, nil }, from: y}
+}
+
+func SubVar(y vars.Name) Operation {
+	return arithmetic{symbol: "-=", op: func(x, y int) (int, error) { return x - y, nil }, from: y}
+}
+
+func MulVar(y vars.Name) Operation {
+	return arithmetic{symbol: "*=", op: func(x, y int) (int, error) { return x * y, nil }, from: y}
+}
+
+func ModVar(y vars.Name) Operation {
+	return arithmetic{symbol: "%=", op: modulo, from: y}
+}
+
+func modulo(x, y int) (int, error) {
+	if y == 0 {
+		return 0, fmt.Errorf("modulo by zero")
+	}
+	return x % y, nil
+}
+
+// arithmetic updates a variable by the operand,
+// which is the value of the variable if from is not empty.
+type arithmetic struct {
+	symbol string
+	op     func(x, y int) (int, error)
+	val    int
+	from   vars.Name
+}
+
+func (o arithmetic) ToVar(x vars.Name) Action {
+	return calculating{arithmetic: o, to: x}
+}
+
+type calculating struct {
+	arithmetic
+	to vars.Name
+}
+
+func (a calculating) Apply(vs vars.Shared) (vars.Shared, error) {
+	modified := vs.Clone()
+	if _, ok := modified[a.to]; !ok {
+		return vars.Shared{}, fmt.Errorf("undeclared variable: %s", a.to)
+	}
+	operand := a.val
+	if a.from != "" {
+		n, ok := vs[a.from]
+		if !ok {
+			return vars.Shared{}, fmt.Errorf("undeclared variable: %s", a.from)
+		}
+		operand = n
+	}
+	n, err := a.op(vs[a.to], operand)
+	if err != nil {
+		return vars.Shared{}, err
+	}
+	modified[a.to] = n
+	return modified, nil
+}
+
+func (a calculating) Reads() []vars.Name {
+	if a.from != "" {
+		return []vars.Name{a.to, a.from}
+	}
+	return []vars.Name{a.to}
+}
+
+func (a calculating) Writes() []vars.Name {
+	return []vars.Name{a.to}
+}
+
+func (a calculating) Describe() string {
+	if a.from != "" {
+		return fmt.Sprintf("%s %s %s", a.to, a.symbol, a.from)
+	}
+	return fmt.Sprintf("%s %s %d", a.to, a.symbol, a.val)
+}
+
+// Swap exchanges the values of the variables.
+func Swap(x, y vars.Name) Action {
+	return swapping{left: x, right: y}
+}
+
+type swapping struct {
+	left  vars.Name
+	right vars.Name
+}
+
+func (a swapping) Apply(vs vars.Shared) (vars.Shared, error) {
+	modified := vs.Clone()
+	for _, x := range []vars.Name{a.left, a.right} {
 		if _, ok := modified[x]; !ok {
 			return vars.Shared{}, fmt.Errorf("undeclared variable: %s", x)
 		}
-		modified[x] = vs[x] + o.val
-		return modified, nil
 	}
+	modified[a.left], modified[a.right] = vs[a.right], vs[a.left]
+	return modified, nil
+}
+
+func (a swapping) Reads() []vars.Name {
+	return []vars.Name{a.left, a.right}
+}
+
+func (a swapping) Writes() []vars.Name {
+	return []vars.Name{a.left, a.right}
+}
+
+func (a swapping) Describe() string {
+	return fmt.Sprintf("%s, %s := %s, %s", a.left, a.right, a.right, a.left)
+}
+
+// Seq applies the actions in order as a single action,
+// which stops at the first error. The footprint is known
+// only if the footprints of all actions are known.
+func Seq(as ...Action) Action {
+	s := sequence{actions: as}
+	for _, a := range as {
+		if _, ok := a.(vars.Described); !ok {
+			return ActionFunc(s.Apply)
+		}
+	}
+	return s
+}
+
+type sequence struct {
+	actions []Action
+}
+
+func (a sequence) Apply(vs vars.Shared) (vars.Shared, error) {
+	modified := vs.Clone()
+	for _, b := range a.actions {
+		var err error
+		modified, err = b.Apply(modified)
+		if err != nil {
+			return vars.Shared{}, err
+		}
+	}
+	return modified, nil
+}
+
+func (a sequence) Reads() []vars.Name {
+	xs := []vars.Name{}
+	for _, b := range a.actions {
+		xs = append(xs, b.(vars.Footprint).Reads()...)
+	}
+	return xs
+}
+
+func (a sequence) Writes() []vars.Name {
+	xs := []vars.Name{}
+	for _, b := range a.actions {
+		xs = append(xs, b.(vars.Footprint).Writes()...)
+	}
+	return xs
+}
+
+func (a sequence) Describe() string {
+	if len(a.actions) == 0 {
+		return "nothing"
+	}
+	ds := []string{}
+	for _, b := range a.actions {
+		ds = append(ds, Describe(b))
+	}
+	return strings.Join(ds, "; ")
+}
+
+// If applies the action then if the guard holds, or the action otherwise.
+// The branch not taken is not applied, but its variables are still
+// checked to be declared if its footprint is known, so that an undeclared
+// variable is reported regardless of the guard.
+// The footprint, which is known only if the guard and both actions know theirs,
+// contains the accesses of both actions.
+// It panics if the guard or either action is nil.
+func If(g when.Guard, then, otherwise Action) Action {
+	if g == nil || then == nil || otherwise == nil {
+		panic("do.If: nil guard or action")
+	}
+	c := conditional{guard: g, then: then, otherwise: otherwise}
+	_, known := g.(vars.Described)
+	for _, a := range []Action{then, otherwise} {
+		if _, ok := a.(vars.Described); !ok {
+			known = false
+		}
+	}
+	if !known {
+		return ActionFunc(c.Apply)
+	}
+	return c
+}
+
+type conditional struct {
+	guard     when.Guard
+	then      Action
+	otherwise Action
+}
+
+func (a conditional) Apply(vs vars.Shared) (vars.Shared, error) {
+	ok, err := a.guard.Test(vs)
+	if err != nil {
+		return vars.Shared{}, err
+	}
+	taken, skipped := a.then, a.otherwise
+	if !ok {
+		taken, skipped = skipped, taken
+	}
+	if f, known := FootprintOf(skipped); known {
+		for _, x := range append(f.Reads(), f.Writes()...) {
+			if _, ok := vs[x]; !ok {
+				return vars.Shared{}, fmt.Errorf("undeclared variable: %s", x)
+			}
+		}
+	}
+	return taken.Apply(vs)
+}
+
+func (a conditional) Reads() []vars.Name {
+	xs := append([]vars.Name{}, a.guard.(vars.Footprint).Reads()...)
+	xs = append(xs, a.then.(vars.Footprint).Reads()...)
+	return append(xs, a.otherwise.(vars.Footprint).Reads()...)
+}
+
+func (a conditional) Writes() []vars.Name {
+	xs := append([]vars.Name{}, a.then.(vars.Footprint).Writes()...)
+	return append(xs, a.otherwise.(vars.Footprint).Writes()...)
+}
+
+func (a conditional) Describe() string {
+	return fmt.Sprintf("if %s then %s else %s",
+		when.Describe(a.guard), Describe(a.then), Describe(a.otherwise))
 }
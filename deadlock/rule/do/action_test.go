@@ -1,10 +1,12 @@
 package do_test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
 )
 
 func TestNothing(t *testing.T) {
@@ -29,7 +31,7 @@ func TestNothing(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := do.Nothing()(tt.want)
+			got, err := do.Nothing().Apply(tt.want)
 			if err != nil {
 				t.Fatalf("want no error, but has error %v", err)
 			}
@@ -73,7 +75,7 @@ func TestCopyVar(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := do.CopyVar(tt.from).ToVar(tt.to)(tt.in)
+			got, err := do.CopyVar(tt.from).ToVar(tt.to).Apply(tt.in)
 			if tt.wantError && err == nil {
 				t.Fatalf("want error, but has no error")
 			}
@@ -114,7 +116,7 @@ func TestSet(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := do.Set(tt.val).ToVar(tt.to)(tt.in)
+			got, err := do.Set(tt.val).ToVar(tt.to).Apply(tt.in)
 			if tt.wantError && err == nil {
 				t.Fatalf("want error, but has no error")
 			}
@@ -155,7 +157,7 @@ func TestAdd(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := do.Add(tt.val).ToVar(tt.to)(tt.in)
+			got, err := do.Add(tt.val).ToVar(tt.to).Apply(tt.in)
 			if tt.wantError && err == nil {
 				t.Fatalf("want error, but has no error")
 			}
@@ -181,3 +183,204 @@ func eqVars(got, want vars.Shared) bool {
 	}
 	return true
 }
+
+func TestFootprintOf(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		in       do.Action
+		reads    []vars.Name
+		writes   []vars.Name
+		describe string
+		known    bool
+	}{
+		{
+			name: "nothing", in: do.Nothing(),
+			reads: []vars.Name{}, writes: []vars.Name{},
+			describe: "nothing", known: true,
+		},
+		{
+			name: "copy var", in: do.CopyVar("y").ToVar("x"),
+			reads: []vars.Name{"y"}, writes: []vars.Name{"x"},
+			describe: "x := y", known: true,
+		},
+		{
+			name: "set", in: do.Set(42).ToVar("x"),
+			reads: []vars.Name{}, writes: []vars.Name{"x"},
+			describe: "x := 42", known: true,
+		},
+		{
+			name: "add", in: do.Add(42).ToVar("x"),
+			reads: []vars.Name{"x"}, writes: []vars.Name{"x"},
+			describe: "x += 42", known: true,
+		},
+		{
+			name: "add var", in: do.AddVar("y").ToVar("x"),
+			reads: []vars.Name{"x", "y"}, writes: []vars.Name{"x"},
+			describe: "x += y", known: true,
+		},
+		{
+			name: "mod", in: do.Mod(3).ToVar("x"),
+			reads: []vars.Name{"x"}, writes: []vars.Name{"x"},
+			describe: "x %= 3", known: true,
+		},
+		{
+			name: "swap", in: do.Swap("x", "y"),
+			reads: []vars.Name{"x", "y"}, writes: []vars.Name{"x", "y"},
+			describe: "x, y := y, x", known: true,
+		},
+		{
+			name: "seq", in: do.Seq(do.Set(0).ToVar("x"), do.CopyVar("z").ToVar("y")),
+			reads: []vars.Name{"z"}, writes: []vars.Name{"x", "y"},
+			describe: "x := 0; y := z", known: true,
+		},
+		{
+			name: "if", in: do.If(when.Var("z").Is(0), do.Set(1).ToVar("x"), do.Sub(1).ToVar("y")),
+			reads: []vars.Name{"z", "y"}, writes: []vars.Name{"x", "y"},
+			describe: "if z == 0 then x := 1 else y -= 1", known: true,
+		},
+		{
+			name: "mul var", in: do.MulVar("y").ToVar("x"),
+			reads: []vars.Name{"x", "y"}, writes: []vars.Name{"x"},
+			describe: "x *= y", known: true,
+		},
+		{
+			name: "user-defined",
+			in: do.ActionFunc(func(vs vars.Shared) (vars.Shared, error) {
+				return vs.Clone(), nil
+			}),
+			describe: "user-defined action", known: false,
+		},
+		{
+			name: "seq with user-defined",
+			in: do.Seq(do.Nothing(), do.ActionFunc(func(vs vars.Shared) (vars.Shared, error) {
+				return vs.Clone(), nil
+			})),
+			describe: "user-defined action", known: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := do.FootprintOf(tt.in)
+			if ok != tt.known {
+				t.Fatalf("want known %v, but %v", tt.known, ok)
+			}
+			if ok && !reflect.DeepEqual(got.Reads(), tt.reads) {
+				t.Fatalf("want reads %v, but %v", tt.reads, got.Reads())
+			}
+			if ok && !reflect.DeepEqual(got.Writes(), tt.writes) {
+				t.Fatalf("want writes %v, but %v", tt.writes, got.Writes())
+			}
+			if d := do.Describe(tt.in); d != tt.describe {
+				t.Fatalf("want %q, but %q", tt.describe, d)
+			}
+		})
+	}
+
+}
+
+func TestComposite(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		action    do.Action
+		in        vars.Shared
+		want      vars.Shared
+		wantError bool
+	}{
+		{"sub", do.Sub(2).ToVar("x"), vars.Shared{"x": 5}, vars.Shared{"x": 3}, false},
+		{"mul", do.Mul(2).ToVar("x"), vars.Shared{"x": 5}, vars.Shared{"x": 10}, false},
+		{"mod", do.Mod(3).ToVar("x"), vars.Shared{"x": 5}, vars.Shared{"x": 2}, false},
+		{"mod by zero", do.Mod(0).ToVar("x"), vars.Shared{"x": 5}, vars.Shared{}, true},
+		{"sub undefined", do.Sub(2).ToVar("x"), vars.Shared{}, vars.Shared{}, true},
+		{"add var", do.AddVar("y").ToVar("x"), vars.Shared{"x": 1, "y": 2}, vars.Shared{"x": 3, "y": 2}, false},
+		{"add undefined var", do.AddVar("y").ToVar("x"), vars.Shared{"x": 1}, vars.Shared{}, true},
+		{"sub var", do.SubVar("y").ToVar("x"), vars.Shared{"x": 1, "y": 2}, vars.Shared{"x": -1, "y": 2}, false},
+		{"mul var", do.MulVar("y").ToVar("x"), vars.Shared{"x": 3, "y": 2}, vars.Shared{"x": 6, "y": 2}, false},
+		{"mod var", do.ModVar("y").ToVar("x"), vars.Shared{"x": 5, "y": 3}, vars.Shared{"x": 2, "y": 3}, false},
+		{"mod var by zero", do.ModVar("y").ToVar("x"), vars.Shared{"x": 5, "y": 0}, vars.Shared{}, true},
+		{"mul undefined var", do.MulVar("y").ToVar("x"), vars.Shared{"x": 1}, vars.Shared{}, true},
+		{"swap", do.Swap("x", "y"), vars.Shared{"x": 1, "y": 2}, vars.Shared{"x": 2, "y": 1}, false},
+		{"swap undefined", do.Swap("x", "y"), vars.Shared{"x": 1}, vars.Shared{}, true},
+		{
+			"seq", do.Seq(do.CopyVar("x").ToVar("y"), do.Add(1).ToVar("x")),
+			vars.Shared{"x": 1, "y": 0}, vars.Shared{"x": 2, "y": 1}, false,
+		},
+		{"seq of none", do.Seq(), vars.Shared{"x": 1}, vars.Shared{"x": 1}, false},
+		{
+			"seq undefined", do.Seq(do.Set(1).ToVar("x"), do.Set(1).ToVar("y")),
+			vars.Shared{"x": 0}, vars.Shared{}, true,
+		},
+		{
+			"if then", do.If(when.Var("x").Is(0), do.Set(1).ToVar("y"), do.Set(2).ToVar("y")),
+			vars.Shared{"x": 0, "y": 0}, vars.Shared{"x": 0, "y": 1}, false,
+		},
+		{
+			"if else", do.If(when.Var("x").Is(0), do.Set(1).ToVar("y"), do.Set(2).ToVar("y")),
+			vars.Shared{"x": 1, "y": 0}, vars.Shared{"x": 1, "y": 2}, false,
+		},
+		{
+			"if undefined", do.If(when.Var("z").Is(0), do.Nothing(), do.Nothing()),
+			vars.Shared{"x": 0}, vars.Shared{}, true,
+		},
+		{
+			"if undefined in else", do.If(when.Var("x").Is(0), do.Set(1).ToVar("y"), do.Set(2).ToVar("z")),
+			vars.Shared{"x": 0, "y": 0}, vars.Shared{}, true,
+		},
+		{
+			"if undefined in then", do.If(when.Var("x").Is(0), do.SubVar("z").ToVar("y"), do.Set(2).ToVar("y")),
+			vars.Shared{"x": 1, "y": 0}, vars.Shared{}, true,
+		},
+		{
+			"if guarding mod", do.If(when.Var("y").IsNot(0), do.ModVar("y").ToVar("x"), do.Nothing()),
+			vars.Shared{"x": 5, "y": 0}, vars.Shared{"x": 5, "y": 0}, false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.in.Clone()
+			got, err := tt.action.Apply(tt.in)
+			if tt.wantError && err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+			if !tt.wantError && err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !tt.wantError && !eqVars(got, tt.want) {
+				t.Fatalf("want %+v, but %+v", tt.want, got)
+			}
+			if !eqVars(tt.in, before) {
+				t.Fatalf("want the input unchanged %+v, but %+v", before, tt.in)
+			}
+		})
+	}
+
+}
+
+func TestIfNil(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		guard     when.Guard
+		then      do.Action
+		otherwise do.Action
+	}{
+		{"nil guard", nil, do.Nothing(), do.Nothing()},
+		{"nil then", when.Always(), nil, do.Nothing()},
+		{"nil else", when.Always(), do.Nothing(), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("want panic, but has no panic")
+				}
+			}()
+			do.If(tt.guard, tt.then, tt.otherwise)
+		})
+	}
+
+}
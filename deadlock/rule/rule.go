@@ -2,11 +2,16 @@
 package rule
 
 import (
+	"fmt"
+
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
 )
 
+// Event is the name of a synchronization between processes.
+type Event string
+
 // Location represents the program counter of each process.
 type Location string
 
@@ -15,6 +20,20 @@ type Label string
 
 type RuleSet map[Location][]Rule
 
+// Fairness is an assumption on the scheduler used in the liveness checking.
+type Fairness int
+
+const (
+	// Unfair rules may be ignored by the scheduler forever.
+	Unfair Fairness = iota
+	// Weak fairness assumes that if the rule is continuously fireable,
+	// it is eventually fired.
+	Weak
+	// Strong fairness assumes that if the rule is infinitely often fireable,
+	// it is infinitely often fired.
+	Strong
+)
+
 // Rule defines transition rules of the process.
 type Rule interface {
 	Source() Location
@@ -22,31 +41,105 @@ type Rule interface {
 	Guard() when.Guard
 	Label() Label
 	Action() do.Action
+	// IsProgress tells whether firing the rule means
+	// that the process makes some progress, e.g. eating or sending a message.
+	IsProgress() bool
+	Fairness() Fairness
+	// Footprint returns the shared variables which the guard and the action access,
+	// together with the channel and the event of the rule. It reports false
+	// if the footprint is unknown, i.e. the rule has a user-defined guard
+	// or action and no footprint is declared by Touch.
+	Footprint() (vars.Footprint, bool)
 	Only(when.Guard) Rule
 	Let(Label, do.Action) Rule
 	MoveTo(Location) Rule
+	MarkProgress() Rule
+	Fair(Fairness) Rule
+	Touch(vars.Footprint) Rule
+	// Communication returns the channel operation of the rule, if any.
+	Communication() (Communication, bool)
+	Send(ch vars.Name, n int) Rule
+	SendVar(ch vars.Name, x vars.Name) Rule
+	Receive(ch vars.Name, x vars.Name) Rule
+	// Event returns the synchronization event of the rule, if any.
+	Event() (Event, bool)
+	Sync(Event) Rule
+}
+
+// Direction tells whether a communication sends or receives a message.
+type Direction int
+
+const (
+	Sending Direction = iota
+	Receiving
+)
+
+// Communication is an operation on a channel declared in the system.
+// The rule is fireable only if the guard holds and the channel is ready,
+// i.e. not full for sending or not empty for receiving.
+// On an unbuffered channel, the sending rule and the receiving rule
+// of another process are fired together. The message is sent
+// or received before the action of the rule is applied.
+type Communication struct {
+	Channel   vars.Name
+	Direction Direction
+	// Value is the message sent if Var is empty.
+	Value int
+	// Var is the variable whose value is sent, or which the message is received into.
+	// The received message is discarded if it is empty.
+	Var vars.Name
+}
+
+// Footprint regards the channel as a variable which is read and written.
+func (c Communication) Footprint() vars.Footprint {
+	reads := []vars.Name{c.Channel}
+	writes := []vars.Name{c.Channel}
+	if c.Var != "" && c.Direction == Sending {
+		reads = append(reads, c.Var)
+	}
+	if c.Var != "" && c.Direction == Receiving {
+		writes = append(writes, c.Var)
+	}
+	return vars.Access(reads, writes)
+}
+
+func (c Communication) String() string {
+	switch {
+	case c.Direction == Receiving:
+		return fmt.Sprintf("%s?%s", c.Channel, c.Var)
+	case c.Var != "":
+		return fmt.Sprintf("%s!%s", c.Channel, c.Var)
+	}
+	return fmt.Sprintf("%s!%d", c.Channel, c.Value)
 }
 
 func At(l Location) Rule {
 	return rule{
-		source: l,
-		target: l,
-		label:  "",
-		guard: func(_ vars.Shared) (bool, error) {
-			return true, nil
-		},
-		action: func(vs vars.Shared) (vars.Shared, error) {
-			return vs.Clone(), nil
-		},
+		source:          l,
+		target:          l,
+		label:           "",
+		guard:           when.Always(),
+		action:          do.Nothing(),
+		guardFootprint:  vars.Access(nil, nil),
+		actionFootprint: vars.Access(nil, nil),
 	}
 }
 
 type rule struct {
-	source Location
-	target Location
-	guard  when.Guard
-	label  Label
-	action do.Action
+	source   Location
+	target   Location
+	guard    when.Guard
+	label    Label
+	action   do.Action
+	progress bool
+	fairness Fairness
+	// nil footprints are unknown
+	guardFootprint  vars.Footprint
+	actionFootprint vars.Footprint
+	declared        vars.Footprint
+	// nil unless the rule communicates over a channel
+	communication *Communication
+	event         Event
 }
 
 func (r rule) Source() Location {
@@ -69,14 +162,45 @@ func (r rule) Action() do.Action {
 	return r.action
 }
 
+func (r rule) IsProgress() bool {
+	return r.progress
+}
+
+func (r rule) Fairness() Fairness {
+	return r.fairness
+}
+
+func (r rule) Footprint() (vars.Footprint, bool) {
+	fs := []vars.Footprint{r.declared}
+	if r.declared == nil {
+		if r.guardFootprint == nil || r.actionFootprint == nil {
+			return nil, false
+		}
+		fs = []vars.Footprint{r.guardFootprint, r.actionFootprint}
+	}
+	if r.communication != nil {
+		fs = append(fs, r.communication.Footprint())
+	}
+	if r.event != "" {
+		// the event is regarded as a variable which every participant writes
+		e := []vars.Name{vars.Name(r.event)}
+		fs = append(fs, vars.Access(e, e))
+	}
+	return vars.Union(fs...), true
+}
+
+// Only sets the guard of the rule, which replaces the previous one.
+// Guards are combined by when.All or when.Any.
 func (r rule) Only(g when.Guard) Rule {
 	r.guard = g
+	r.guardFootprint, _ = when.FootprintOf(g)
 	return r
 }
 
 func (r rule) Let(lbl Label, a do.Action) Rule {
 	r.label = lbl
 	r.action = a
+	r.actionFootprint, _ = do.FootprintOf(a)
 	return r
 }
 
@@ -84,3 +208,68 @@ func (r rule) MoveTo(l Location) Rule {
 	r.target = l
 	return r
 }
+
+func (r rule) MarkProgress() Rule {
+	r.progress = true
+	return r
+}
+
+func (r rule) Fair(f Fairness) Rule {
+	r.fairness = f
+	return r
+}
+
+// Touch declares the footprint of the guard and the action together,
+// which takes precedence over the ones of them.
+// The channel and the event of the rule are still regarded as accessed.
+func (r rule) Touch(f vars.Footprint) Rule {
+	r.declared = f
+	return r
+}
+
+func (r rule) Communication() (Communication, bool) {
+	if r.communication == nil {
+		return Communication{}, false
+	}
+	return *r.communication, true
+}
+
+// Send sends the constant to the channel.
+// The label defaults to the operation, e.g. "ch!1", unless it is given by Let.
+func (r rule) Send(ch vars.Name, n int) Rule {
+	return r.communicate(Communication{Channel: ch, Direction: Sending, Value: n})
+}
+
+// SendVar sends the value of the variable to the channel.
+func (r rule) SendVar(ch vars.Name, x vars.Name) Rule {
+	return r.communicate(Communication{Channel: ch, Direction: Sending, Var: x})
+}
+
+// Receive receives a message from the channel into the variable.
+func (r rule) Receive(ch vars.Name, x vars.Name) Rule {
+	return r.communicate(Communication{Channel: ch, Direction: Receiving, Var: x})
+}
+
+func (r rule) communicate(c Communication) Rule {
+	r.communication = &c
+	if r.label == "" {
+		r.label = Label(c.String())
+	}
+	return r
+}
+
+func (r rule) Event() (Event, bool) {
+	return r.event, r.event != ""
+}
+
+// Sync makes the rule synchronize on the event, in the CSP style.
+// A rule on the event is fired only together with the rules on the same event
+// of every other process which has some rule on it, as one joint transition.
+// The label defaults to the event, unless it is given by Let.
+func (r rule) Sync(e Event) Rule {
+	r.event = e
+	if r.label == "" {
+		r.label = Label(e)
+	}
+	return r
+}
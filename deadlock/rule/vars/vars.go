@@ -13,3 +13,63 @@ func (vs Shared) Clone() Shared {
 	}
 	return c
 }
+
+// Footprint is the set of shared variables which guards or actions access.
+type Footprint interface {
+	Reads() []Name
+	Writes() []Name
+}
+
+type footprint struct {
+	reads  []Name
+	writes []Name
+}
+
+// Access declares a footprint which reads and writes the given variables.
+func Access(reads, writes []Name) Footprint {
+	return footprint{reads: reads, writes: writes}
+}
+
+func (f footprint) Reads() []Name {
+	return f.reads
+}
+
+func (f footprint) Writes() []Name {
+	return f.writes
+}
+
+// Union merges the footprints into one.
+func Union(fs ...Footprint) Footprint {
+	u := footprint{reads: []Name{}, writes: []Name{}}
+	for _, f := range fs {
+		u.reads = append(u.reads, f.Reads()...)
+		u.writes = append(u.writes, f.Writes()...)
+	}
+	return u
+}
+
+// Independent tells whether the accesses never conflict,
+// i.e. neither writes the variables which the other reads or writes.
+func Independent(f, g Footprint) bool {
+	return !overlaps(f.Writes(), g.Reads()) &&
+		!overlaps(f.Writes(), g.Writes()) &&
+		!overlaps(f.Reads(), g.Writes())
+}
+
+func overlaps(xs, ys []Name) bool {
+	for _, x := range xs {
+		for _, y := range ys {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Described is implemented by guards and actions
+// which can tell their footprints and what they do.
+type Described interface {
+	Footprint
+	Describe() string
+}
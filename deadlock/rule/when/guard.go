@@ -3,6 +3,7 @@ package when
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
 )
@@ -10,7 +11,58 @@ import (
 // Guard determines whether a transition is fireable
 // under the given values of shared variables.
 // If the specified variable name is undeclared, it returns an error.
-type Guard func(vars.Shared) (bool, error)
+//
+// The guards built by this package also implement vars.Described,
+// so that tools can tell which variables they read.
+type Guard interface {
+	Test(vars.Shared) (bool, error)
+}
+
+// GuardFunc is an adapter to use a user-defined function as a Guard.
+// Its footprint is unknown.
+type GuardFunc func(vars.Shared) (bool, error)
+
+func (f GuardFunc) Test(vs vars.Shared) (bool, error) {
+	return f(vs)
+}
+
+// FootprintOf returns the variables which the guard reads.
+// It reports false if the guard does not know its footprint.
+func FootprintOf(g Guard) (vars.Footprint, bool) {
+	f, ok := g.(vars.Footprint)
+	return f, ok
+}
+
+// Describe returns the human-readable description of the guard.
+func Describe(g Guard) string {
+	if d, ok := g.(vars.Described); ok {
+		return d.Describe()
+	}
+	return "user-defined guard"
+}
+
+// Always is the guard which is always true.
+func Always() Guard {
+	return always{}
+}
+
+type always struct{}
+
+func (_ always) Test(_ vars.Shared) (bool, error) {
+	return true, nil
+}
+
+func (_ always) Reads() []vars.Name {
+	return []vars.Name{}
+}
+
+func (_ always) Writes() []vars.Name {
+	return []vars.Name{}
+}
+
+func (_ always) Describe() string {
+	return "true"
+}
 
 type Testee struct {
 	name vars.Name
@@ -21,27 +73,225 @@ func Var(x vars.Name) Testee {
 }
 
 func (t Testee) Is(n int) Guard {
-	return t.check(func(x, y int) bool { return x == y }, n)
+	return t.check("==", func(x, y int) bool { return x == y }, n)
 }
 
 func (t Testee) IsNot(n int) Guard {
-	return t.check(func(x, y int) bool { return x != y }, n)
+	return t.check("!=", func(x, y int) bool { return x != y }, n)
 }
 
 func (t Testee) IsLessThan(n int) Guard {
-	return t.check(func(x, y int) bool { return x < y }, n)
+	return t.check("<", func(x, y int) bool { return x < y }, n)
 }
 
 func (t Testee) IsGreaterThan(n int) Guard {
-	return t.check(func(x, y int) bool { return x > y }, n)
+	return t.check(">", func(x, y int) bool { return x > y }, n)
+}
+
+func (t Testee) check(symbol string, op func(x, y int) bool, n int) Guard {
+	return comparison{name: t.name, symbol: symbol, op: op, val: n}
+}
+
+// comparison compares a variable with a constant.
+type comparison struct {
+	name   vars.Name
+	symbol string
+	op     func(x, y int) bool
+	val    int
+}
+
+func (c comparison) Test(vs vars.Shared) (bool, error) {
+	val, ok := vs[c.name]
+	if !ok {
+		return false, fmt.Errorf("undeclared variable: %s", c.name)
+	}
+	return c.op(val, c.val), nil
+}
+
+func (c comparison) Reads() []vars.Name {
+	return []vars.Name{c.name}
+}
+
+func (c comparison) Writes() []vars.Name {
+	return []vars.Name{}
+}
+
+func (c comparison) Describe() string {
+	return fmt.Sprintf("%s %s %d", c.name, c.symbol, c.val)
+}
+
+func (t Testee) IsAtLeast(n int) Guard {
+	return t.check(">=", func(x, y int) bool { return x >= y }, n)
+}
+
+func (t Testee) IsAtMost(n int) Guard {
+	return t.check("<=", func(x, y int) bool { return x <= y }, n)
+}
+
+// IsBetween holds when the variable is in the closed range from lo to hi.
+func (t Testee) IsBetween(lo, hi int) Guard {
+	return All(t.IsAtLeast(lo), t.IsAtMost(hi))
+}
+
+func (t Testee) IsVar(y vars.Name) Guard {
+	return t.checkVar("==", func(x, y int) bool { return x == y }, y)
+}
+
+func (t Testee) IsNotVar(y vars.Name) Guard {
+	return t.checkVar("!=", func(x, y int) bool { return x != y }, y)
+}
+
+func (t Testee) IsLessThanVar(y vars.Name) Guard {
+	return t.checkVar("<", func(x, y int) bool { return x < y }, y)
+}
+
+func (t Testee) IsGreaterThanVar(y vars.Name) Guard {
+	return t.checkVar(">", func(x, y int) bool { return x > y }, y)
+}
+
+func (t Testee) IsAtLeastVar(y vars.Name) Guard {
+	return t.checkVar(">=", func(x, y int) bool { return x >= y }, y)
+}
+
+func (t Testee) IsAtMostVar(y vars.Name) Guard {
+	return t.checkVar("<=", func(x, y int) bool { return x <= y }, y)
+}
+
+func (t Testee) checkVar(symbol string, op func(x, y int) bool, y vars.Name) Guard {
+	return varComparison{left: t.name, symbol: symbol, op: op, right: y}
+}
+
+// varComparison compares two variables.
+type varComparison struct {
+	left   vars.Name
+	symbol string
+	op     func(x, y int) bool
+	right  vars.Name
+}
+
+func (c varComparison) Test(vs vars.Shared) (bool, error) {
+	x, ok := vs[c.left]
+	if !ok {
+		return false, fmt.Errorf("undeclared variable: %s", c.left)
+	}
+	y, ok := vs[c.right]
+	if !ok {
+		return false, fmt.Errorf("undeclared variable: %s", c.right)
+	}
+	return c.op(x, y), nil
+}
+
+func (c varComparison) Reads() []vars.Name {
+	return []vars.Name{c.left, c.right}
+}
+
+func (c varComparison) Writes() []vars.Name {
+	return []vars.Name{}
+}
+
+func (c varComparison) Describe() string {
+	return fmt.Sprintf("%s %s %s", c.left, c.symbol, c.right)
+}
+
+// All holds when every guard holds, and Any holds when some guard holds.
+// Every guard is tested regardless of the others' results,
+// so that an undeclared variable is always reported as an error.
+// The footprint is known only if the footprints of all guards are known.
+func All(gs ...Guard) Guard {
+	return combine(junction{guards: gs, symbol: "&&", unit: true})
+}
+
+// Any holds when some guard holds. See All.
+func Any(gs ...Guard) Guard {
+	return combine(junction{guards: gs, symbol: "||", unit: false})
+}
+
+// Not negates the guard.
+func Not(g Guard) Guard {
+	if _, ok := g.(vars.Described); ok {
+		return negation{guard: g}
+	}
+	return GuardFunc(negation{guard: g}.Test)
+}
+
+func combine(j junction) Guard {
+	for _, g := range j.guards {
+		if _, ok := g.(vars.Described); !ok {
+			return GuardFunc(j.Test)
+		}
+	}
+	return j
+}
+
+// junction is the conjunction or the disjunction of guards,
+// whose unit is the result for no guard.
+type junction struct {
+	guards []Guard
+	symbol string
+	unit   bool
 }
 
-func (t Testee) check(op func(x, y int) bool, n int) Guard {
-	return func(vs vars.Shared) (bool, error) {
-		val, ok := vs[t.name]
-		if !ok {
-			return false, fmt.Errorf("undeclared variable: %s", t.name)
+func (j junction) Test(vs vars.Shared) (bool, error) {
+	result := j.unit
+	for _, g := range j.guards {
+		ok, err := g.Test(vs)
+		if err != nil {
+			return false, err
+		}
+		if ok != j.unit {
+			result = !j.unit
 		}
-		return op(val, n), nil
 	}
+	return result, nil
+}
+
+func (j junction) Reads() []vars.Name {
+	xs := []vars.Name{}
+	for _, g := range j.guards {
+		xs = append(xs, g.(vars.Footprint).Reads()...)
+	}
+	return xs
+}
+
+func (j junction) Writes() []vars.Name {
+	return []vars.Name{}
+}
+
+func (j junction) Describe() string {
+	if len(j.guards) == 0 {
+		return fmt.Sprintf("%t", j.unit)
+	}
+	ds := []string{}
+	for _, g := range j.guards {
+		d := Describe(g)
+		if _, ok := g.(junction); ok && len(j.guards) > 1 {
+			d = "(" + d + ")"
+		}
+		ds = append(ds, d)
+	}
+	return strings.Join(ds, " "+j.symbol+" ")
+}
+
+type negation struct {
+	guard Guard
+}
+
+func (n negation) Test(vs vars.Shared) (bool, error) {
+	ok, err := n.guard.Test(vs)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+func (n negation) Reads() []vars.Name {
+	return n.guard.(vars.Footprint).Reads()
+}
+
+func (n negation) Writes() []vars.Name {
+	return []vars.Name{}
+}
+
+func (n negation) Describe() string {
+	return fmt.Sprintf("!(%s)", Describe(n.guard))
 }
@@ -1,6 +1,7 @@
 package when_test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
@@ -36,7 +37,7 @@ func TestIs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := when.Var(tt.var_).Is(tt.val)(tt.in)
+			got, err := when.Var(tt.var_).Is(tt.val).Test(tt.in)
 			if tt.wantError && err == nil {
 				t.Fatalf("want error, but has no error")
 			}
@@ -80,7 +81,7 @@ func TestIsNot(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := when.Var(tt.var_).IsNot(tt.val)(tt.in)
+			got, err := when.Var(tt.var_).IsNot(tt.val).Test(tt.in)
 			if tt.wantError && err == nil {
 				t.Fatalf("want error, but has no error")
 			}
@@ -129,7 +130,7 @@ func TestIsLessThan(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := when.Var(tt.var_).IsLessThan(tt.val)(tt.in)
+			got, err := when.Var(tt.var_).IsLessThan(tt.val).Test(tt.in)
 			if tt.wantError && err == nil {
 				t.Fatalf("want error, but has no error")
 			}
@@ -178,7 +179,128 @@ func TestIsGreaterThan(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := when.Var(tt.var_).IsGreaterThan(tt.val)(tt.in)
+			got, err := when.Var(tt.var_).IsGreaterThan(tt.val).Test(tt.in)
+			if tt.wantError && err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+			if !tt.wantError && err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !tt.wantError && got != tt.want {
+				t.Fatalf("want %+v, but %+v", tt.want, got)
+			}
+		})
+	}
+
+}
+
+func TestFootprintOf(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		in       when.Guard
+		reads    []vars.Name
+		describe string
+		known    bool
+	}{
+		{
+			name: "always", in: when.Always(),
+			reads: []vars.Name{}, describe: "true", known: true,
+		},
+		{
+			name: "is", in: when.Var("x").Is(42),
+			reads: []vars.Name{"x"}, describe: "x == 42", known: true,
+		},
+		{
+			name: "is less than", in: when.Var("x").IsLessThan(42),
+			reads: []vars.Name{"x"}, describe: "x < 42", known: true,
+		},
+		{
+			name: "user-defined",
+			in: when.GuardFunc(func(_ vars.Shared) (bool, error) {
+				return true, nil
+			}),
+			reads: nil, describe: "user-defined guard", known: false,
+		},
+		{
+			name: "between variables", in: when.Var("x").IsLessThanVar("y"),
+			reads: []vars.Name{"x", "y"}, describe: "x < y", known: true,
+		},
+		{
+			name: "is between", in: when.Var("x").IsBetween(1, 3),
+			reads: []vars.Name{"x", "x"}, describe: "x >= 1 && x <= 3", known: true,
+		},
+		{
+			name: "nested", in: when.Any(when.Not(when.Var("x").Is(0)), when.All(when.Var("y").IsAtMost(1), when.Var("z").Is(2))),
+			reads: []vars.Name{"x", "y", "z"}, describe: "!(x == 0) || (y <= 1 && z == 2)", known: true,
+		},
+		{
+			name: "with user-defined",
+			in: when.All(when.Var("x").Is(0), when.GuardFunc(func(_ vars.Shared) (bool, error) {
+				return true, nil
+			})),
+			reads: nil, describe: "user-defined guard", known: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := when.FootprintOf(tt.in)
+			if ok != tt.known {
+				t.Fatalf("want known %v, but %v", tt.known, ok)
+			}
+			if ok && (!reflect.DeepEqual(got.Reads(), tt.reads) || len(got.Writes()) != 0) {
+				t.Fatalf("want reads %v, but %v and writes %v", tt.reads, got.Reads(), got.Writes())
+			}
+			if d := when.Describe(tt.in); d != tt.describe {
+				t.Fatalf("want %q, but %q", tt.describe, d)
+			}
+		})
+	}
+
+}
+
+func TestComposite(t *testing.T) {
+
+	undeclared := when.Var("u").Is(0)
+
+	tests := []struct {
+		name      string
+		guard     when.Guard
+		in        vars.Shared
+		want      bool
+		wantError bool
+	}{
+		{"at least", when.Var("x").IsAtLeast(2), vars.Shared{"x": 2}, true, false},
+		{"not at least", when.Var("x").IsAtLeast(2), vars.Shared{"x": 1}, false, false},
+		{"at most", when.Var("x").IsAtMost(2), vars.Shared{"x": 2}, true, false},
+		{"not at most", when.Var("x").IsAtMost(2), vars.Shared{"x": 3}, false, false},
+		{"between", when.Var("x").IsBetween(1, 3), vars.Shared{"x": 3}, true, false},
+		{"below", when.Var("x").IsBetween(1, 3), vars.Shared{"x": 0}, false, false},
+		{"above", when.Var("x").IsBetween(1, 3), vars.Shared{"x": 4}, false, false},
+		{"is var", when.Var("x").IsVar("y"), vars.Shared{"x": 1, "y": 1}, true, false},
+		{"is not var", when.Var("x").IsNotVar("y"), vars.Shared{"x": 1, "y": 1}, false, false},
+		{"less than var", when.Var("x").IsLessThanVar("y"), vars.Shared{"x": 1, "y": 2}, true, false},
+		{"greater than var", when.Var("x").IsGreaterThanVar("y"), vars.Shared{"x": 1, "y": 2}, false, false},
+		{"at least var", when.Var("x").IsAtLeastVar("y"), vars.Shared{"x": 2, "y": 2}, true, false},
+		{"at most var", when.Var("x").IsAtMostVar("y"), vars.Shared{"x": 3, "y": 2}, false, false},
+		{"undeclared left", when.Var("u").IsVar("y"), vars.Shared{"y": 1}, false, true},
+		{"undeclared right", when.Var("x").IsVar("u"), vars.Shared{"x": 1}, false, true},
+		{"all", when.All(when.Var("x").Is(1), when.Var("y").Is(2)), vars.Shared{"x": 1, "y": 2}, true, false},
+		{"not all", when.All(when.Var("x").Is(1), when.Var("y").Is(2)), vars.Shared{"x": 1, "y": 1}, false, false},
+		{"all of none", when.All(), vars.Shared{}, true, false},
+		{"any", when.Any(when.Var("x").Is(1), when.Var("y").Is(2)), vars.Shared{"x": 0, "y": 2}, true, false},
+		{"not any", when.Any(when.Var("x").Is(1), when.Var("y").Is(2)), vars.Shared{"x": 0, "y": 0}, false, false},
+		{"any of none", when.Any(), vars.Shared{}, false, false},
+		{"not", when.Not(when.Var("x").Is(1)), vars.Shared{"x": 1}, false, false},
+		{"undeclared after false", when.All(when.Var("x").Is(1), undeclared), vars.Shared{"x": 0}, false, true},
+		{"undeclared after true", when.Any(when.Var("x").Is(1), undeclared), vars.Shared{"x": 1}, false, true},
+		{"undeclared negated", when.Not(undeclared), vars.Shared{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.guard.Test(tt.in)
 			if tt.wantError && err == nil {
 				t.Fatalf("want error, but has no error")
 			}
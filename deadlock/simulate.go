@@ -0,0 +1,133 @@
+package deadlock
+
+import (
+	"math/rand"
+)
+
+// SimulationOptions configures the random walks of Simulate.
+type SimulationOptions struct {
+	// Walks is the number of walks, 100 by default.
+	Walks int
+	// Length is the maximum number of steps in a walk, 1000 by default.
+	Length int
+	// Seed initializes the random number generator,
+	// so that the same seed reproduces the same walks.
+	Seed int64
+}
+
+const (
+	defaultWalks  = 100
+	defaultLength = 1000
+)
+
+// SimulationReport contains the states and transitions
+// which the random walks passed through.
+// Its traces, including the ones of TraceTo, are the walks which reached
+// the deadlocked or violating states, rather than the shortest paths to them.
+type SimulationReport interface {
+	Report
+	// Walk returns the exact walk which first reached the state,
+	// if it is deadlocked or violates some invariants.
+	Walk(StateId) []Transition
+}
+
+type simulationReport struct {
+	report
+	walks map[StateId][]Transition
+}
+
+func (sr simulationReport) Walk(id StateId) []Transition {
+	return sr.walks[id]
+}
+
+// Simulate performs random walks from the initial state, each of which
+// fires a rule chosen uniformly among the fireable ones at every step.
+// A walk ends at a deadlocked or halting state, or at the maximum length.
+func Simulate(s System, opts SimulationOptions) (SimulationReport, error) {
+
+	if opts.Walks <= 0 {
+		opts.Walks = defaultWalks
+	}
+	if opts.Length <= 0 {
+		opts.Length = defaultLength
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+
+	ex, err := newExpander(s, Options{})
+	if err != nil {
+		return nil, err
+	}
+	store := NewMemoryStore()
+	accepting := StateSet{}
+	deadlocked := StateSet{}
+	violated := map[StateId][]string{}
+	walks := map[StateId][]Transition{}
+
+	pg := newProgress()
+	initial := ex.initial()
+	for i := 0; i < opts.Walks; i++ {
+		from := initial
+		walk := []Transition{}
+		for {
+			if _, err := store.PutState(from); err != nil {
+				return nil, err
+			}
+			broken, err := violations(s, from)
+			if err != nil {
+				return nil, err
+			}
+			if len(broken) > 0 {
+				if _, ok := violated[from.Id()]; !ok {
+					violated[from.Id()] = broken
+					walks[from.Id()] = append([]Transition{}, walk...)
+				}
+			}
+
+			steps, err := ex.successors(from)
+			if err != nil {
+				return nil, err
+			}
+			if len(steps) == 0 {
+				if acceptable(s, from) {
+					accepting[from.Id()] = from
+					break
+				}
+				if _, ok := deadlocked[from.Id()]; !ok {
+					deadlocked[from.Id()] = from
+					walks[from.Id()] = append([]Transition{}, walk...)
+				}
+				break
+			}
+			if len(walk) >= opts.Length {
+				break
+			}
+
+			st := steps[rnd.Intn(len(steps))]
+			if err := store.PutTransition(st.transition); err != nil {
+				return nil, err
+			}
+			pg.expanded(len(walk), 1)
+			walk = append(walk, st.transition)
+			from = st.state
+		}
+	}
+
+	rp, err := newReport(store, initial.Id(), accepting, deadlocked, violated)
+	if err != nil {
+		return nil, err
+	}
+	rp.stopReason = Simulated
+	rp.coverage = exact
+	rp.channels = s.Channels()
+	rp.statistics = pg.statistics(store, nil, 0)
+	rp.memory = rp.statistics.Memory
+	rp.traces = TransitionSet{}
+	rp.paths = walks
+	for _, w := range walks {
+		for _, t := range w {
+			rp.traces[t.Id()] = t
+		}
+	}
+	return simulationReport{report: rp, walks: walks}, nil
+
+}
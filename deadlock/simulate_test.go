@@ -0,0 +1,67 @@
+package deadlock_test
+
+import (
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+)
+
+func TestSimulate(t *testing.T) {
+
+	in := philosophers(3, philosopher)
+
+	full, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		opts         deadlock.SimulationOptions
+		wantDeadlock bool
+	}{
+		{"default", deadlock.SimulationOptions{Seed: 42}, true},
+		{"short walks", deadlock.SimulationOptions{Walks: 10, Length: 2, Seed: 42}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deadlock.Simulate(in, tt.opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if got.Complete() || got.StopReason() != deadlock.Simulated {
+				t.Fatalf("want simulated, but %v", got.StopReason())
+			}
+			if tt.wantDeadlock != (len(got.Deadlocked()) > 0) {
+				t.Fatalf("want deadlocks %v, but %v", tt.wantDeadlock, got.Deadlocked())
+			}
+			for id := range got.Deadlocked() {
+				if _, ok := full.Deadlocked()[id]; !ok {
+					t.Fatalf("want a deadlock found by the detector, but %s", id)
+				}
+				walk := got.Walk(id)
+				if !connected(got.Initial(), walk) || walk[len(walk)-1].Target() != id {
+					t.Fatalf("want walk to %s, but %v", id, walk)
+				}
+				if len(got.TraceTo(id)) != len(walk) {
+					t.Fatalf("want trace of the walk %v, but %v", walk, got.TraceTo(id))
+				}
+			}
+			for id := range got.Visited() {
+				if _, ok := full.Visited()[id]; !ok {
+					t.Fatalf("want a reachable state, but %s", id)
+				}
+			}
+
+			again, err := deadlock.Simulate(in, tt.opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !eqReports(again, got) {
+				t.Fatalf("want reproduced %+v, but %+v", summarize(got), summarize(again))
+			}
+		})
+	}
+
+}
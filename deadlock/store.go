@@ -0,0 +1,198 @@
+package deadlock
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+)
+
+// StateStore keeps the states and transitions found during the search.
+// Implementations must be safe for concurrent use,
+// since the parallel detector looks up states from multiple goroutines.
+// A store should not be shared among searches.
+type StateStore interface {
+	// PutState stores the state unless a state of the same id is stored,
+	// and tells whether the state is newly stored.
+	PutState(State) (bool, error)
+	HasState(StateId) bool
+	GetState(StateId) (State, bool, error)
+	CountStates() int
+	EachState(func(State) error) error
+	// PutTransition stores the transition unless it is already stored.
+	PutTransition(Transition) error
+	GetTransition(TransitionId) (Transition, bool, error)
+	EachTransition(func(Transition) error) error
+}
+
+// NewMemoryStore returns a store which keeps everything in Go maps.
+// It is the default store of the detectors.
+func NewMemoryStore() StateStore {
+	return &memoryStore{
+		states:      StateSet{},
+		transitions: TransitionSet{},
+	}
+}
+
+type memoryStore struct {
+	mu          sync.RWMutex
+	states      StateSet
+	transitions TransitionSet
+	// the sizes of the states are counted as they are stored, see measure
+	keyBytes     int64
+	payloadBytes int64
+	withLocals   int
+}
+
+func (ms *memoryStore) PutState(s State) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.states[s.Id()]; ok {
+		return false, nil
+	}
+	ms.states[s.Id()] = s
+	ms.keyBytes += int64(len(s.Id()) + len(s.Upstream()))
+	ms.payloadBytes += locationsSize(s.Locations()) + varsSize(s.SharedVars()) + localsSize(s.LocalVars())
+	if len(s.LocalVars()) > 0 {
+		ms.withLocals++
+	}
+	return true, nil
+}
+
+func (ms *memoryStore) HasState(id StateId) bool {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	_, ok := ms.states[id]
+	return ok
+}
+
+func (ms *memoryStore) GetState(id StateId) (State, bool, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	s, ok := ms.states[id]
+	return s, ok, nil
+}
+
+func (ms *memoryStore) CountStates() int {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return len(ms.states)
+}
+
+func (ms *memoryStore) EachState(f func(State) error) error {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for _, s := range ms.states {
+		if err := f(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *memoryStore) PutTransition(t Transition) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.transitions[t.Id()] = t
+	return nil
+}
+
+func (ms *memoryStore) GetTransition(id TransitionId) (Transition, bool, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	t, ok := ms.transitions[id]
+	return t, ok, nil
+}
+
+func (ms *memoryStore) EachTransition(f func(Transition) error) error {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	for _, t := range ms.transitions {
+		if err := f(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupState tells whether the state is stored. Since the ids are hashes,
+// the stored state of the same id is compared with the given one
+// and an error is returned if they differ. Lossy stores are not compared,
+// because they tolerate the collisions by design.
+func lookupState(store StateStore, s State) (bool, error) {
+	if _, ok := store.(lossyStore); ok {
+		return store.HasState(s.Id()), nil
+	}
+	stored, ok, err := store.GetState(s.Id())
+	if err != nil || !ok {
+		return false, err
+	}
+	if !sameState(stored, s) {
+		return false, collisionError(s.Id())
+	}
+	return true, nil
+}
+
+func collisionError(id StateId) error {
+	return fmt.Errorf("hash collision between distinct states: %s", id)
+}
+
+// sameState compares the contents of the states, apart from their upstreams.
+func sameState(s, t State) bool {
+	if len(s.Locations()) != len(t.Locations()) {
+		return false
+	}
+	for pid, l := range s.Locations() {
+		if m, ok := t.Locations()[pid]; !ok || m != l {
+			return false
+		}
+	}
+	if !sameVars(s.SharedVars(), t.SharedVars()) {
+		return false
+	}
+	// processes without local variables may be missing in either
+	for pid, vs := range s.LocalVars() {
+		if !sameVars(vs, t.LocalVars()[pid]) {
+			return false
+		}
+	}
+	for pid, vs := range t.LocalVars() {
+		if !sameVars(vs, s.LocalVars()[pid]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameVars(vs, ws vars.Shared) bool {
+	if len(vs) != len(ws) {
+		return false
+	}
+	for x, n := range vs {
+		if m, ok := ws[x]; !ok || m != n {
+			return false
+		}
+	}
+	return true
+}
+
+// loadStates collects every state in the store.
+// The memory store hands over its own map without copying.
+func loadStates(st StateStore) (StateSet, error) {
+	ss := StateSet{}
+	err := st.EachState(func(s State) error {
+		ss[s.Id()] = s
+		return nil
+	})
+	return ss, err
+}
+
+// loadTransitions collects every transition in the store.
+func loadTransitions(st StateStore) (TransitionSet, error) {
+	ts := TransitionSet{}
+	err := st.EachTransition(func(t Transition) error {
+		ts[t.Id()] = t
+		return nil
+	})
+	return ts, err
+}
@@ -0,0 +1,112 @@
+package deadlock_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+)
+
+func TestDiskStore(t *testing.T) {
+
+	in := philosophers(3, politePhilosopher).
+		Assert("nobody eats", deadlock.AtMost(0, "2", "3"))
+
+	tests := []struct {
+		name     string
+		detector deadlock.Detector
+	}{
+		{"sequential", deadlock.NewDetector()},
+		{"parallel", deadlock.NewParallelDetector(4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ddsv")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			store, err := deadlock.NewDiskStore(dir)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			defer store.Close()
+
+			opts := deadlock.Options{Livelock: true}
+			want, err := tt.detector.DetectContext(context.Background(), in, opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			opts.Store = store
+			got, err := tt.detector.DetectContext(context.Background(), in, opts)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !eqReports(got, want) {
+				t.Fatalf("want %+v, but %+v", summarize(want), summarize(got))
+			}
+			if len(got.Livelocks()) != len(want.Livelocks()) {
+				t.Fatalf("want %d livelocks, but %d", len(want.Livelocks()), len(got.Livelocks()))
+			}
+			if err := store.Err(); err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+
+			if _, err := tt.detector.DetectContext(context.Background(), in, opts); err == nil {
+				t.Fatalf("want error for the used store, but has no error")
+			}
+		})
+	}
+
+}
+
+// collidingStore pretends that every state has the same id as the first one.
+type collidingStore struct {
+	deadlock.StateStore
+	first deadlock.State
+}
+
+func (cs *collidingStore) PutState(s deadlock.State) (bool, error) {
+	if cs.first == nil {
+		cs.first = s
+	}
+	return cs.StateStore.PutState(s)
+}
+
+func (cs *collidingStore) HasState(id deadlock.StateId) bool {
+	return cs.first != nil
+}
+
+func (cs *collidingStore) GetState(id deadlock.StateId) (deadlock.State, bool, error) {
+	return cs.first, cs.first != nil, nil
+}
+
+func TestStateCollision(t *testing.T) {
+
+	in := deadlock.NewSystem().
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").MoveTo("1")))
+
+	tests := []struct {
+		name     string
+		detector deadlock.Detector
+	}{
+		{"sequential", deadlock.NewDetector()},
+		{"parallel", deadlock.NewParallelDetector(2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := deadlock.Options{Store: &collidingStore{StateStore: deadlock.NewMemoryStore()}}
+			if _, err := tt.detector.DetectContext(context.Background(), in, opts); err == nil {
+				t.Fatalf("want error, but has no error")
+			}
+		})
+	}
+
+}
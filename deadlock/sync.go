@@ -0,0 +1,221 @@
+package deadlock
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+)
+
+// alphabets returns the processes which have some rule on each event,
+// in the order of registration.
+func alphabets(s System) map[rule.Event][]ProcessId {
+	as := map[rule.Event][]ProcessId{}
+	for _, p := range s.Processes() {
+		seen := map[rule.Event]bool{}
+		for _, rs := range p.Rules() {
+			for _, r := range rs {
+				e, ok := r.Event()
+				if !ok || seen[e] {
+					continue
+				}
+				seen[e] = true
+				as[e] = append(as[e], p.Id())
+			}
+		}
+	}
+	return as
+}
+
+func validateEvents(s System) error {
+	for _, p := range s.Processes() {
+		for _, rs := range p.Rules() {
+			for _, r := range rs {
+				_, synced := r.Event()
+				_, communicated := r.Communication()
+				if synced && communicated {
+					return fmt.Errorf("rule %s of %s both synchronizes and communicates", r.Label(), p.Id())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// joint tells whether the rule is fired only in joint transitions,
+// in which the process takes the lead.
+func (ex expander) joint(r rule.Rule) bool {
+	_, synced := r.Event()
+	return synced || ex.unbuffered(r, rule.Sending)
+}
+
+// synchronize fires the rule on an event together with the rules on the event
+// of every other process in the alphabet, for each combination of them.
+// The joint transitions are fired only by the first process in the alphabet.
+func (ex expander) synchronize(p Process, from State, ref ruleRef) ([]step, error) {
+
+	e, _ := p.Rules()[ref.source][ref.index].Event()
+	pids := ex.alphabets[e]
+	if len(pids) == 0 || pids[0] != p.Id() {
+		return []step{}, nil
+	}
+
+	combinations := [][]participant{{}}
+	for _, pid := range pids[1:] {
+		q := ex.process(pid)
+		focus := from.Locations()[pid]
+		options := []participant{}
+		for i, o := range q.Rules()[focus] {
+			if f, ok := o.Event(); ok && f == e {
+				options = append(options, participant{process: pid, label: o.Label(), rule: ruleRef{source: focus, index: i}})
+			}
+		}
+		extended := [][]participant{}
+		for _, c := range combinations {
+			for _, o := range options {
+				extended = append(extended, append(append([]participant{}, c...), o))
+			}
+		}
+		combinations = extended
+	}
+
+	steps := []step{}
+	for _, partners := range combinations {
+		st, ok, err := ex.fireJoint(p, from, ref, partners)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			steps = append(steps, st)
+		}
+	}
+	return steps, nil
+
+}
+
+// process returns the registered process with the id, or nil if not found.
+func (ex expander) process(pid ProcessId) Process {
+	for _, p := range ex.system.Processes() {
+		if p.Id() == pid {
+			return p
+		}
+	}
+	return nil
+}
+
+// fireJoint fires the rule of the process and the rules of the partners
+// as one transition, if all of them are fireable. If the process sends
+// over an unbuffered channel, the partner receives the message.
+// The actions are applied in the order of the process and the partners.
+func (ex expander) fireJoint(p Process, from State, ref ruleRef, partners []participant) (step, bool, error) {
+
+	r := p.Rules()[ref.source][ref.index]
+	rs := []rule.Rule{r}
+	pids := []ProcessId{p.Id()}
+	moves := map[ProcessId]rule.Location{p.Id(): r.Target()}
+	for _, partner := range partners {
+		q := ex.process(partner.process)
+		if q == nil {
+			return step{}, false, fmt.Errorf("unknown process: %s", partner.process)
+		}
+		if from.Locations()[q.Id()] != partner.rule.source {
+			return step{}, false, nil
+		}
+		o := q.Rules()[partner.rule.source][partner.rule.index]
+		rs = append(rs, o)
+		pids = append(pids, q.Id())
+		moves[q.Id()] = o.Target()
+	}
+
+	for i, o := range rs {
+		fireable, err := o.Guard().Test(view(from.SharedVars(), from.LocalVars(), pids[i]))
+		if err != nil || !fireable {
+			return step{}, false, err
+		}
+	}
+
+	shared, locals := from.SharedVars(), from.LocalVars()
+	if send, ok := r.Communication(); ok && len(rs) == 2 {
+		// the message is sent before received
+		receive, _ := rs[1].Communication()
+		n, err := message(send, view(shared, locals, pids[0]))
+		if err != nil {
+			return step{}, false, err
+		}
+		vs, err := deliver(receive, n, view(shared, locals, pids[1]))
+		if err != nil {
+			return step{}, false, err
+		}
+		shared, locals = split(vs, locals, pids[1])
+	}
+	progress := false
+	for i, o := range rs {
+		vs, err := o.Action().Apply(view(shared, locals, pids[i]))
+		if err != nil {
+			return step{}, false, err
+		}
+		shared, locals = split(vs, locals, pids[i])
+		progress = progress || o.IsProgress()
+	}
+
+	t := transition{
+		process:  p.Id(),
+		label:    r.Label(),
+		progress: progress,
+		rule:     ref,
+		partners: partners,
+	}
+	return ex.move(from, moves, shared, locals, t), true, nil
+
+}
+
+// fireTransition fires the rules of the transition again from the given state,
+// if the participants are at the sources of the rules and they are fireable.
+func (ex expander) fireTransition(from State, t transition) (step, bool, error) {
+	p := ex.process(t.process)
+	if p == nil {
+		return step{}, false, fmt.Errorf("unknown process: %s", t.process)
+	}
+	if from.Locations()[p.Id()] != t.rule.source {
+		return step{}, false, nil
+	}
+	if ex.joint(p.Rules()[t.rule.source][t.rule.index]) {
+		return ex.fireJoint(p, from, t.rule, t.partners)
+	}
+	return ex.fireRule(p, from, t.rule)
+}
+
+// eventBlocked tells which participant in the alphabet of the event
+// the rule on the event waits for, or returns an empty string
+// if every participant is ready.
+func eventBlocked(s System, alphabet []ProcessId, st State, pid ProcessId, e rule.Event) (string, error) {
+	waiting := []string{}
+	for _, qid := range alphabet {
+		if qid == pid {
+			continue
+		}
+		ready := false
+		for _, q := range s.Processes() {
+			if q.Id() != qid {
+				continue
+			}
+			for _, o := range q.Rules()[st.Locations()[qid]] {
+				if f, ok := o.Event(); !ok || f != e {
+					continue
+				}
+				fireable, err := o.Guard().Test(view(st.SharedVars(), st.LocalVars(), qid))
+				if err != nil {
+					return "", err
+				}
+				ready = ready || fireable
+			}
+		}
+		if !ready {
+			waiting = append(waiting, string(qid))
+		}
+	}
+	if len(waiting) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("%s not ready for %s", strings.Join(waiting, ", "), e), nil
+}
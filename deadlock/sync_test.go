@@ -0,0 +1,150 @@
+package deadlock_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/y-taka-23/ddsv-go/deadlock"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/do"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/vars"
+	"github.com/y-taka-23/ddsv-go/deadlock/rule/when"
+)
+
+func TestSync(t *testing.T) {
+
+	sequence := func(first, second rule.Event) deadlock.Process {
+		return deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Sync(first).MoveTo("1")).
+			Define(rule.At("1").Sync(second).MoveTo("2")).
+			HaltAt("2")
+	}
+
+	tests := []struct {
+		name         string
+		in           deadlock.System
+		wantStates   int
+		wantDeadlock bool
+	}{
+		{"same order", deadlock.NewSystem().
+			Register("P", sequence("a", "b")).
+			Register("Q", sequence("a", "b")), 3, false},
+		{"crossing order", deadlock.NewSystem().
+			Register("P", sequence("a", "b")).
+			Register("Q", sequence("b", "a")), 1, true},
+		{"private events", deadlock.NewSystem().
+			Register("P", sequence("a", "b")).
+			Register("Q", sequence("c", "d")), 9, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := deadlock.NewDetector().Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if len(got.Visited()) != tt.wantStates || tt.wantDeadlock != (len(got.Deadlocked()) > 0) {
+				t.Fatalf("want %d states and deadlocks %v, but %+v", tt.wantStates, tt.wantDeadlock, summarize(got))
+			}
+			reduced, err := deadlock.NewDetector().DetectContext(context.Background(), tt.in, deadlock.Options{PartialOrder: true})
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !eqStateIds(reduced.Deadlocked(), got.Deadlocked()) {
+				t.Fatalf("want deadlocks %v, but %v", got.Deadlocked(), reduced.Deadlocked())
+			}
+			parallel, err := deadlock.NewParallelDetector(2).Detect(tt.in)
+			if err != nil {
+				t.Fatalf("want no error, but has error %v", err)
+			}
+			if !eqReports(parallel, got) {
+				t.Fatalf("want %+v, but %+v", summarize(got), summarize(parallel))
+			}
+		})
+	}
+
+}
+
+func TestSyncJoint(t *testing.T) {
+
+	// R joins the barrier only after x is set, which nobody does
+	in := deadlock.NewSystem().
+		Declare(vars.Shared{"x": 0, "y": 0}).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Sync("go").Let("left", do.Add(1).ToVar("y")).MoveTo("1")).
+			Define(rule.At("0").Sync("go").Let("right", do.Add(2).ToVar("y")).MoveTo("2"))).
+		Register("Q", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Sync("go").MoveTo("1"))).
+		Register("R", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Let("skip", do.Nothing()).MoveTo("1")).
+			Define(rule.At("1").Sync("go").MoveTo("2")).
+			Define(rule.At("0").Only(when.Var("x").Is(1)).Sync("go").MoveTo("2")))
+
+	got, err := deadlock.NewDetector().Detect(in)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	joint := 0
+	for _, tr := range got.Transited() {
+		if tr.Label() != "left" && tr.Label() != "right" {
+			continue
+		}
+		joint++
+		if !eqProcessIds(tr.Processes(), []deadlock.ProcessId{"P", "Q", "R"}) {
+			t.Fatalf("want joint transition of P, Q and R, but %v", tr.Processes())
+		}
+	}
+	if joint != 2 {
+		t.Fatalf("want 2 joint transitions, but %d", joint)
+	}
+
+	initial := got.Visited()[got.Initial()]
+	exp, err := deadlock.Explain(in, initial)
+	if err != nil {
+		t.Fatalf("want no error, but has error %v", err)
+	}
+	if r := exp.Processes[0].Rules[0]; r.Fireable || r.Event != "go" || r.Sync != "R not ready for go" {
+		t.Fatalf("want waiting for R, but %+v", r)
+	}
+
+	for id := range got.Deadlocked() {
+		min, err := deadlock.Minimize(in, got, id)
+		if err != nil {
+			t.Fatalf("want no error, but has error %v", err)
+		}
+		if !connected(got.Initial(), min) || len(min) != len(got.TraceTo(id)) {
+			t.Fatalf("want the trace %v replayed, but %v", got.TraceTo(id), min)
+		}
+	}
+
+}
+
+func TestSyncError(t *testing.T) {
+
+	in := deadlock.NewSystem().
+		Channel("ch", 1).
+		Register("P", deadlock.NewProcess().
+			EnterAt("0").
+			Define(rule.At("0").Sync("go").Send("ch", 1).MoveTo("1")))
+
+	if _, err := deadlock.NewDetector().Detect(in); err == nil {
+		t.Fatalf("want error, but has no error")
+	}
+
+}
+
+func eqProcessIds(xs, ys []deadlock.ProcessId) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if xs[i] != ys[i] {
+			return false
+		}
+	}
+	return true
+}
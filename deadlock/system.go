@@ -13,9 +13,19 @@ type Process interface {
 	EntryPoint() rule.Location
 	Rules() rule.RuleSet
 	HaltingPoints() []rule.Location
+	// Fairness is the assumption on the scheduler of the process as a whole,
+	// i.e. if any rule of the process is fireable.
+	Fairness() rule.Fairness
+	// LocalVars returns the initial values of the variables local to the process,
+	// which only the guards and the actions of the process can access.
+	LocalVars() vars.Shared
 	EnterAt(rule.Location) Process
+	// Declare declares the local variables, which should not be named
+	// as the shared ones since they are seen together by the rules.
+	Declare(vars.Shared) Process
 	Define(rule.Rule) Process
 	HaltAt(...rule.Location) Process
+	Fair(rule.Fairness) Process
 }
 
 func NewProcess() Process {
@@ -24,6 +34,7 @@ func NewProcess() Process {
 		entryPoint:    "",
 		rules:         rule.RuleSet{},
 		haltingPoints: []rule.Location{},
+		localVars:     vars.Shared{},
 	}
 }
 
@@ -32,6 +43,8 @@ type process struct {
 	entryPoint    rule.Location
 	rules         rule.RuleSet
 	haltingPoints []rule.Location
+	fairness      rule.Fairness
+	localVars     vars.Shared
 }
 
 func (p process) Id() ProcessId {
@@ -70,36 +83,174 @@ func (p process) HaltingPoints() []rule.Location {
 	return p.haltingPoints
 }
 
+func (p process) Fairness() rule.Fairness {
+	return p.fairness
+}
+
+func (p process) Fair(f rule.Fairness) Process {
+	p.fairness = f
+	return p
+}
+
+func (p process) LocalVars() vars.Shared {
+	return p.localVars
+}
+
+func (p process) Declare(decls vars.Shared) Process {
+	p.localVars = decls.Clone()
+	return p
+}
+
+// Predicate tells whether a state of the system satisfies a property.
+// If the specified variable name is undeclared, it returns an error.
+type Predicate func(LocationSet, vars.Shared) (bool, error)
+
+// AtMost holds when at most n processes are at any of the given locations,
+// e.g. AtMost(1, "critical") represents the mutual exclusion.
+func AtMost(n int, ls ...rule.Location) Predicate {
+	return func(locs LocationSet, _ vars.Shared) (bool, error) {
+		count := 0
+		for _, focus := range locs {
+			for _, l := range ls {
+				if focus == l {
+					count++
+					break
+				}
+			}
+		}
+		return count <= n, nil
+	}
+}
+
+// Invariant is a named predicate which every reachable state should satisfy.
+type Invariant interface {
+	Name() string
+	Predicate() Predicate
+}
+
+type invariant struct {
+	name      string
+	predicate Predicate
+}
+
+func (inv invariant) Name() string {
+	return inv.name
+}
+
+func (inv invariant) Predicate() Predicate {
+	return inv.predicate
+}
+
+// Replica is a member of a symmetric group of processes,
+// which owns some shared variables, e.g. its local temporary.
+type Replica interface {
+	Id() ProcessId
+	Process() Process
+	Owned() []vars.Name
+}
+
+func NewReplica(pid ProcessId, p Process, owned ...vars.Name) Replica {
+	return replica{id: pid, process: p, owned: owned}
+}
+
+type replica struct {
+	id      ProcessId
+	process Process
+	owned   []vars.Name
+}
+
+func (r replica) Id() ProcessId {
+	return r.id
+}
+
+func (r replica) Process() Process {
+	return r.process
+}
+
+func (r replica) Owned() []vars.Name {
+	return r.owned
+}
+
 // System represents a set of processes.
 // In the deadlock detection, they act concurrently
 // accessing the pre-declared global shared variables.
 type System interface {
 	InitVars() vars.Shared
+	// Declared returns the variables declared by Declare,
+	// i.e. InitVars without the variables of the channels.
+	Declared() vars.Shared
 	Processes() []Process
+	Invariants() []Invariant
+	// Symmetries returns the groups of processes registered as symmetric.
+	Symmetries() [][]Replica
 	Declare(vars.Shared) System
+	// Channels returns the capacities of the declared channels.
+	Channels() map[vars.Name]int
+	// Channel declares a channel with the capacity, which is unbuffered if it is zero.
+	// The messages of a buffered channel are stored in shared variables
+	// named after the channel, e.g. "ch.len" and "ch[0]", which are
+	// included in InitVars and must not be declared by users.
+	Channel(vars.Name, int) System
 	Register(ProcessId, Process) System
+	// RegisterSymmetric registers the replicas as a symmetric group,
+	// i.e. the detector identifies states which differ only in
+	// a permutation of the replicas and their owned variables.
+	// The replicas should be built from the same definition
+	// renaming their owned variables, and the values of variables
+	// should not identify any particular replica. Invariants and
+	// propositions which tell the replicas apart are reported as errors,
+	// and fairness cannot be assumed in the livelock detection or CheckLTL.
+	RegisterSymmetric(...Replica) System
+	Assert(string, Predicate) System
 }
 
 func NewSystem() System {
 	return system{
-		initVars:  vars.Shared{},
-		processes: []Process{},
+		initVars:   vars.Shared{},
+		processes:  []Process{},
+		invariants: []Invariant{},
+		symmetries: [][]Replica{},
+		channels:   map[vars.Name]int{},
 	}
 }
 
 type system struct {
-	initVars  vars.Shared
-	processes []Process
+	initVars   vars.Shared
+	processes  []Process
+	invariants []Invariant
+	symmetries [][]Replica
+	channels   map[vars.Name]int
 }
 
 func (s system) InitVars() vars.Shared {
-	return s.initVars
+	if len(s.channels) == 0 {
+		return s.initVars
+	}
+	vs := s.initVars.Clone()
+	for ch, capacity := range s.channels {
+		for _, x := range channelVars(ch, capacity) {
+			vs[x] = 0
+		}
+	}
+	return vs
 }
 
 func (s system) Processes() []Process {
 	return s.processes
 }
 
+func (s system) Invariants() []Invariant {
+	return s.invariants
+}
+
+func (s system) Symmetries() [][]Replica {
+	return s.symmetries
+}
+
+func (s system) Declared() vars.Shared {
+	return s.initVars
+}
+
 func (s system) Declare(decls vars.Shared) System {
 	vs := vars.Shared{}
 	for x, n := range decls {
@@ -109,13 +260,43 @@ func (s system) Declare(decls vars.Shared) System {
 	return s
 }
 
+func (s system) Channels() map[vars.Name]int {
+	return s.channels
+}
+
+func (s system) Channel(ch vars.Name, capacity int) System {
+	chs := map[vars.Name]int{}
+	for c, n := range s.channels {
+		chs[c] = n
+	}
+	chs[ch] = capacity
+	s.channels = chs
+	return s
+}
+
 func (s system) Register(pid ProcessId, p Process) System {
 	registered := process{
 		id:            pid,
 		entryPoint:    p.EntryPoint(),
 		rules:         p.Rules(),
 		haltingPoints: p.HaltingPoints(),
+		fairness:      p.Fairness(),
+		localVars:     p.LocalVars(),
 	}
 	s.processes = append(s.processes, registered)
 	return s
 }
+
+func (s system) RegisterSymmetric(rs ...Replica) System {
+	registered := s
+	for _, r := range rs {
+		registered = registered.Register(r.Id(), r.Process()).(system)
+	}
+	registered.symmetries = append(registered.symmetries, rs)
+	return registered
+}
+
+func (s system) Assert(name string, p Predicate) System {
+	s.invariants = append(s.invariants, invariant{name: name, predicate: p})
+	return s
+}
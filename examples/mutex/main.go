@@ -13,26 +13,29 @@ import (
 
 func main() {
 
-	proc := func(global, local, mutex vars.Name) deadlock.Process {
+	proc := func(global, mutex vars.Name) deadlock.Process {
 		return deadlock.NewProcess().
+			Declare(vars.Shared{"tmp": 0}).
 			EnterAt("0").
 			Define(rule.At("0").Only(when.Var(mutex).Is(0)).
 				Let("lock", do.Set(1).ToVar(mutex)).MoveTo("1")).
 			Define(rule.At("1").
-				Let("read", do.CopyVar(global).ToVar(local)).MoveTo("2")).
+				Let("read", do.CopyVar(global).ToVar("tmp")).MoveTo("2")).
 			Define(rule.At("2").
-				Let("incr", do.Add(1).ToVar(local)).MoveTo("3")).
+				Let("incr", do.Add(1).ToVar("tmp")).MoveTo("3")).
 			Define(rule.At("3").
-				Let("write", do.CopyVar(local).ToVar(global)).MoveTo("4")).
+				Let("write", do.CopyVar("tmp").ToVar(global)).MoveTo("4")).
 			Define(rule.At("4").
 				Let("unlock", do.Set(0).ToVar(mutex)).MoveTo("5")).
 			HaltAt("5")
 	}
 
 	system := deadlock.NewSystem().
-		Declare(vars.Shared{"var": 0, "tmp1": 0, "tmp2": 0, "mut": 0}).
-		Register("P", proc("var", "tmp1", "mut")).
-		Register("Q", proc("var", "tmp2", "mut"))
+		Declare(vars.Shared{"var": 0, "mut": 0}).
+		RegisterSymmetric(
+			deadlock.NewReplica("P", proc("var", "mut")),
+			deadlock.NewReplica("Q", proc("var", "mut"))).
+		Assert("mutual exclusion", deadlock.AtMost(1, "1", "2", "3", "4"))
 
 	report, err := deadlock.NewDetector().Detect(system)
 	if err != nil {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -19,7 +20,7 @@ func main() {
 			Define(rule.At("0").Only(when.Var(left).Is(0)).
 				Let("up_l", do.Set(me).ToVar(left)).MoveTo("1")).
 			Define(rule.At("1").Only(when.Var(right).Is(0)).
-				Let("up_r", do.Set(me).ToVar(right)).MoveTo("2")).
+				Let("up_r", do.Set(me).ToVar(right)).MoveTo("2").MarkProgress()).
 			// comment in the lines to avoid deadlocks
 			//Define(rule.At("1").Only(when.Var(right).IsNot(0)).
 			//	Let("down_l", do.Set(0).ToVar(left)).MoveTo("0")).
@@ -34,7 +35,9 @@ func main() {
 		Register("P1", philo(1, "f1", "f2")).
 		Register("P2", philo(2, "f2", "f1"))
 
-	report, err := deadlock.NewDetector().Detect(system)
+	// the fixed model is free from deadlocks, but still livelocks
+	report, err := deadlock.NewDetector().
+		DetectContext(context.Background(), system, deadlock.Options{Livelock: true})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 	}
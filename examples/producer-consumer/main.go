@@ -16,12 +16,12 @@ func main() {
 	capacity := 1
 
 	waitConditionVar := func(mutex, cond vars.Name) do.Action {
-		return func(vs vars.Shared) (vars.Shared, error) {
+		return do.ActionFunc(func(vs vars.Shared) (vars.Shared, error) {
 			newVars := vs.Clone()
 			newVars[mutex] = 0
 			newVars[cond] = 1
 			return newVars, nil
-		}
+		})
 	}
 
 	producer := func(queue, mutex, over, under vars.Name) deadlock.Process {